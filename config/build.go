@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"go-ez-auth/core"
+	"go-ez-auth/middleware"
+)
+
+// Auth is the result of building a Spec: a ready Authenticator, the named stores, and
+// the route protection rules.
+type Auth struct {
+	Authenticator *core.Authenticator
+	Stores        map[string]core.UserStore
+	Routes        []RouteSpec
+}
+
+// Build constructs the stores, strategies, and routes described by spec. Stores are built
+// first so strategies can refer to them by name. On error, whatever was built is closed.
+func Build(spec *Spec) (*Auth, error) {
+	env := &Env{stores: make(map[string]core.UserStore)}
+	auth := &Auth{Authenticator: core.NewAuthenticator(), Stores: env.stores, Routes: append([]RouteSpec(nil), spec.Routes...)}
+	if err := build(spec, env, auth.Authenticator); err != nil {
+		auth.Close()
+		return nil, err
+	}
+	if _, err := auth.router(); err != nil {
+		auth.Close()
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return auth, nil
+}
+
+// build fills env with the stores of spec and registers its strategies with a, then checks
+// that its routes name registered strategies.
+func build(spec *Spec, env *Env, a *core.Authenticator) error {
+	names := make([]string, 0, len(spec.Stores))
+	for name := range spec.Stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := spec.Stores[name]
+		f, ok := storeFactory(s.Type)
+		if !ok {
+			return fmt.Errorf("config: store %q: unknown type %q", name, s.Type)
+		}
+		store, err := f(s.Options, env)
+		if err != nil {
+			return fmt.Errorf("config: store %q: %w", name, err)
+		}
+		env.stores[name] = store
+	}
+
+	for i, s := range spec.Strategies {
+		f, ok := strategyFactory(s.Type)
+		if !ok {
+			return fmt.Errorf("config: strategy %d: unknown type %q", i, s.Type)
+		}
+		strat, err := f(s.Options, env)
+		if err != nil {
+			return fmt.Errorf("config: strategy %d (%s): %w", i, s.Type, err)
+		}
+		if s.Name != "" {
+			strat = core.Named(s.Name, strat)
+		}
+		if _, ok := a.Strategy(strat.Name()); ok {
+			closeStrategy(strat)
+			return fmt.Errorf("config: strategy %d (%s): duplicate name %q; set \"name\" to register it under another", i, s.Type, strat.Name())
+		}
+		if err := a.Register(strat); err != nil {
+			return fmt.Errorf("config: strategy %d (%s): %w", i, s.Type, err)
+		}
+	}
+
+	for _, route := range spec.Routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("config: route %q: path must start with \"/\"", route.Path)
+		}
+		if _, err := chainMode(route.ChainMode); err != nil {
+			return fmt.Errorf("config: route %q: %w", route.Path, err)
+		}
+		for _, name := range route.Strategies {
+			if _, ok := a.Strategy(name); !ok {
+				return fmt.Errorf("config: route %q: unknown strategy %q", route.Path, name)
+			}
+		}
+	}
+	return nil
+}
+
+// closeStrategy closes s if it implements io.Closer.
+func closeStrategy(s core.Strategy) error {
+	if c, ok := s.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// chainMode parses a RouteSpec.ChainMode.
+func chainMode(s string) (core.ChainMode, error) {
+	switch s {
+	case "", "stop_on_invalid":
+		return core.StopOnInvalid, nil
+	case "continue_on_invalid":
+		return core.ContinueOnInvalid, nil
+	}
+	return 0, fmt.Errorf("unknown chain_mode %q", s)
+}
+
+// Middleware returns a net/http middleware applying the most specific route whose path is a
+// prefix of the request's, compared whole segments at a time. Requests matching no route, or a
+// public route, pass through. Paths are matched as by middleware.NewRouter, so a request for a
+// non-canonical path such as "/pub/../api/x" is redirected to its canonical form. Middleware
+// panics if Routes was changed after Build to hold conflicting paths.
+func (a *Auth) Middleware() func(http.Handler) http.Handler {
+	router, err := a.router()
+	if err != nil {
+		panic(err)
+	}
+	return router
+}
+
+// router builds the middleware.NewRouter for Routes. Each path is registered both as itself
+// and, unless it already ends in "/", as the subtree below it, so "/pub" covers "/pub" and
+// "/pub/x" but not "/publish".
+func (a *Auth) router() (func(http.Handler) http.Handler, error) {
+	routes := []middleware.Route{}
+	root := false
+	for _, spec := range a.Routes {
+		mode, _ := chainMode(spec.ChainMode)
+		route := middleware.Route{Strategies: spec.Strategies, ChainMode: mode, Public: spec.Public}
+		patterns := []string{spec.Path}
+		if !strings.HasSuffix(spec.Path, "/") {
+			patterns = append(patterns, spec.Path+"/")
+		}
+		for _, pattern := range patterns {
+			route.Pattern = pattern
+			routes = append(routes, route)
+		}
+		root = root || spec.Path == "/"
+	}
+	if !root {
+		routes = append(routes, middleware.Route{Pattern: "/", Public: true})
+	}
+	return middleware.NewRouter(middleware.Config{Authenticator: a.Authenticator}, routes)
+}
+
+// Close releases the resources of the strategies and then of the stores that implement
+// io.Closer, returning the errors joined.
+func (a *Auth) Close() error {
+	errs := []error{a.Authenticator.Close()}
+	for _, store := range a.Stores {
+		if c, ok := store.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,302 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/gorilla/sessions"
+	"go-ez-auth/core"
+	"go-ez-auth/stores"
+	"go-ez-auth/strategies/apikey"
+	"go-ez-auth/strategies/jwt"
+	"go-ez-auth/strategies/local"
+	"go-ez-auth/strategies/oauth2"
+	"go-ez-auth/strategies/session"
+	xoauth2 "golang.org/x/oauth2"
+)
+
+func init() {
+	RegisterStoreFactory("memory", newMemoryStore)
+	RegisterStoreFactory("apikey", newAPIKeyStore)
+	RegisterStrategyFactory("jwt", newJWT)
+	RegisterStrategyFactory("apikey", newAPIKey)
+	RegisterStrategyFactory("local", newLocal)
+	RegisterStrategyFactory("session", newSession)
+	RegisterStrategyFactory("oauth2", newOAuth2)
+}
+
+// userSpec is a user record declared inline in a config file.
+type userSpec struct {
+	ID         string                 `json:"id"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+func (u userSpec) user() *stores.User {
+	return &stores.User{ID: u.ID, Attributes: u.Attributes}
+}
+
+// newMemoryStore builds a stores.InMemoryUserStore:
+//
+//	type: memory
+//	users: [{id: u1, attributes: {...}}]
+func newMemoryStore(opts Options, env *Env) (core.UserStore, error) {
+	var o struct {
+		Users []userSpec `json:"users"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	users := make([]core.User, len(o.Users))
+	for i, u := range o.Users {
+		users[i] = u.user()
+	}
+	return stores.NewInMemoryUserStore(users...), nil
+}
+
+// newAPIKeyStore builds a stores.APIKeyStore. Each key is given either in full or, to keep
+// plaintext out of the file, by the public ID and digest returned by APIKeyStore.Digest:
+//
+//	type: apikey
+//	prefix: ezk                  # optional
+//	pepper: {env: API_KEY_PEPPER} # optional
+//	keys:
+//	  - key: {env: SVC_KEY}
+//	    user: {id: svc, attributes: {...}}
+//	  - id: 6hq3kz2xqe4tbmtg
+//	    digest: 9f86d081884c7d65...
+//	    user: {id: ops}
+func newAPIKeyStore(opts Options, env *Env) (core.UserStore, error) {
+	var o struct {
+		Prefix string `json:"prefix"`
+		Pepper Secret `json:"pepper"`
+		Keys   []struct {
+			Key    Secret   `json:"key"`
+			ID     string   `json:"id"`
+			Digest string   `json:"digest"`
+			User   userSpec `json:"user"`
+		} `json:"keys"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	cfg := stores.APIKeyStoreConfig{Prefix: o.Prefix}
+	if o.Pepper != (Secret{}) {
+		pepper, err := o.Pepper.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("pepper: %w", err)
+		}
+		cfg.Pepper = []byte(pepper)
+	}
+	s := stores.NewAPIKeyStoreWithConfig(cfg, nil)
+	for i, k := range o.Keys {
+		if k.ID != "" {
+			if err := s.AddDigest(k.ID, k.Digest, k.User.user()); err != nil {
+				return nil, fmt.Errorf("key %d: %w", i, err)
+			}
+			continue
+		}
+		key, err := k.Key.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		s.Add(key, k.User.user())
+	}
+	return s, nil
+}
+
+// optionalStore resolves name if it is set.
+func optionalStore(env *Env, name string) (core.UserStore, error) {
+	if name == "" {
+		return nil, nil
+	}
+	return env.Store(name)
+}
+
+// newJWT builds a jwt strategy:
+//
+//	type: jwt
+//	signing_key: {env: JWT_SECRET}
+//	signing_method: HS256
+//	issuer: ...
+//	audience: ...
+//	realm: api
+//	store: users   # optional
+func newJWT(opts Options, env *Env) (core.Strategy, error) {
+	var o struct {
+		SigningKey    Secret `json:"signing_key"`
+		SigningMethod string `json:"signing_method"`
+		Issuer        string `json:"issuer"`
+		Audience      string `json:"audience"`
+		Realm         string `json:"realm"`
+		Store         string `json:"store"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	key, err := o.SigningKey.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("signing_key: %w", err)
+	}
+	store, err := optionalStore(env, o.Store)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.New(jwt.Config{
+		SigningKey:    []byte(key),
+		SigningMethod: o.SigningMethod,
+		Issuer:        o.Issuer,
+		Audience:      o.Audience,
+		Realm:         o.Realm,
+		Store:         store,
+	}), nil
+}
+
+// newAPIKey builds an apikey strategy:
+//
+//	type: apikey
+//	header: X-API-Key
+//	query_param: api_key
+//	store: keys
+func newAPIKey(opts Options, env *Env) (core.Strategy, error) {
+	var o struct {
+		Header     string `json:"header"`
+		QueryParam string `json:"query_param"`
+		CredKey    string `json:"cred_key"`
+		Realm      string `json:"realm"`
+		Store      string `json:"store"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	store, err := env.Store(o.Store)
+	if err != nil {
+		return nil, err
+	}
+	return apikey.New(apikey.Config{HeaderName: o.Header, QueryParam: o.QueryParam, CredKey: o.CredKey, Realm: o.Realm, Store: store}), nil
+}
+
+// newLocal builds a local (Basic Auth) strategy:
+//
+//	type: local
+//	realm: intranet
+//	store: users   # must support username/password lookups
+func newLocal(opts Options, env *Env) (core.Strategy, error) {
+	var o struct {
+		Realm string `json:"realm"`
+		Store string `json:"store"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	store, err := env.Store(o.Store)
+	if err != nil {
+		return nil, err
+	}
+	return local.New(local.Config{UserStore: store, Realm: o.Realm}), nil
+}
+
+// newSession builds a cookie session strategy:
+//
+//	type: session
+//	secret: {env: SESSION_SECRET}
+//	session_name: sess
+//	key: user_id
+//	store: users
+func newSession(opts Options, env *Env) (core.Strategy, error) {
+	var o struct {
+		Secret      Secret `json:"secret"`
+		SessionName string `json:"session_name"`
+		Key         string `json:"key"`
+		Store       string `json:"store"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	secret, err := o.Secret.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("secret: %w", err)
+	}
+	store, err := env.Store(o.Store)
+	if err != nil {
+		return nil, err
+	}
+	if o.SessionName == "" {
+		o.SessionName = "session"
+	}
+	if o.Key == "" {
+		o.Key = "user_id"
+	}
+	return session.New(session.Config{
+		Store:       sessions.NewCookieStore([]byte(secret)),
+		SessionName: o.SessionName,
+		Key:         o.Key,
+		UserStore:   store,
+	}), nil
+}
+
+// newOAuth2 builds an OAuth2 strategy whose users are built from the userinfo response:
+//
+//	type: oauth2
+//	client_id: ...
+//	client_secret: {env: OAUTH_SECRET}
+//	auth_url: ...
+//	token_url: ...
+//	redirect_url: ...
+//	scopes: [openid, email]
+//	userinfo_url: ...
+//	id_field: sub
+func newOAuth2(opts Options, env *Env) (core.Strategy, error) {
+	var o struct {
+		ClientID     string   `json:"client_id"`
+		ClientSecret Secret   `json:"client_secret"`
+		AuthURL      string   `json:"auth_url"`
+		TokenURL     string   `json:"token_url"`
+		RedirectURL  string   `json:"redirect_url"`
+		Scopes       []string `json:"scopes"`
+		UserInfoURL  string   `json:"userinfo_url"`
+		IDField      string   `json:"id_field"`
+	}
+	if err := opts.Decode(&o); err != nil {
+		return nil, err
+	}
+	secret, err := o.ClientSecret.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("client_secret: %w", err)
+	}
+	idField := o.IDField
+	if idField == "" {
+		idField = "sub"
+	}
+	return oauth2.New(oauth2.Config{
+		OAuth2Config: &xoauth2.Config{
+			ClientID:     o.ClientID,
+			ClientSecret: secret,
+			Endpoint:     xoauth2.Endpoint{AuthURL: o.AuthURL, TokenURL: o.TokenURL},
+			RedirectURL:  o.RedirectURL,
+			Scopes:       o.Scopes,
+		},
+		UserInfoURL: o.UserInfoURL,
+		ExtractUser: func(ctx context.Context, info map[string]interface{}) (core.User, error) {
+			id := userInfoID(info[idField])
+			if id == "" {
+				return nil, fmt.Errorf("userinfo has no %q field", idField)
+			}
+			return &stores.User{ID: id, Attributes: info}, nil
+		},
+	}), nil
+}
+
+// userInfoID formats a userinfo ID field. Numeric IDs, which JSON decodes as float64, are
+// written out in full rather than in exponent form.
+func userInfoID(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,238 @@
+// Package config builds go-ez-auth authenticators from a declarative YAML or JSON file.
+// A file lists stores, strategies, and protected routes; secrets such as signing keys can
+// be given inline, read from an environment variable, or read from a file:
+//
+//	stores:
+//	  users:
+//	    type: memory
+//	    users: [{id: u1, attributes: {roles: [admin]}}]
+//	strategies:
+//	  - type: jwt
+//	    signing_key: {env: JWT_SECRET}
+//	    store: users
+//	routes:
+//	  - path: /api/
+//	    strategies: [jwt]
+//
+// Strategy and store types are looked up in a factory registry; RegisterStrategyFactory
+// and RegisterStoreFactory let third-party packages participate.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the root of a configuration file.
+type Spec struct {
+	Stores     map[string]StoreSpec `json:"stores" yaml:"stores"`
+	Strategies []StrategySpec       `json:"strategies" yaml:"strategies"`
+	Routes     []RouteSpec          `json:"routes" yaml:"routes"`
+}
+
+// Options holds the type-specific settings of a store or strategy.
+type Options map[string]interface{}
+
+// Decode copies the options into target, a pointer to a struct with json tags.
+func (o Options) Decode(target interface{}) error {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(target)
+}
+
+// StoreSpec describes a named user store.
+type StoreSpec struct {
+	Type    string
+	Options Options // every key other than "type"
+}
+
+// StrategySpec describes a strategy. Name registers it under a name other than its own, so that
+// two strategies of one type can be configured side by side.
+type StrategySpec struct {
+	Type    string
+	Name    string  // the "name" key; optional
+	Options Options // every key other than "type" and "name"
+}
+
+// RouteSpec protects requests whose path is Path or lies below it: "/api" covers "/api" and
+// "/api/x" but not "/apix". Path must start with "/", and no two routes may cover the same
+// paths, as "/api" and "/api/" do.
+type RouteSpec struct {
+	Path       string   `json:"path" yaml:"path"`
+	Strategies []string `json:"strategies" yaml:"strategies"`
+	ChainMode  string   `json:"chain_mode,omitempty" yaml:"chain_mode,omitempty"` // "stop_on_invalid" (default) or "continue_on_invalid"
+	Public     bool     `json:"public,omitempty" yaml:"public,omitempty"`         // skip authentication for this prefix
+}
+
+// splitType separates the "type" key from the remaining options.
+func splitType(raw map[string]interface{}) (string, Options, error) {
+	typ, _ := raw["type"].(string)
+	if typ == "" {
+		return "", nil, fmt.Errorf("config: missing \"type\"")
+	}
+	opts := Options{}
+	for k, v := range raw {
+		if k != "type" {
+			opts[k] = v
+		}
+	}
+	return typ, opts, nil
+}
+
+// splitName removes the optional "name" key from a strategy's options.
+func splitName(opts Options) (string, error) {
+	v, ok := opts["name"]
+	if !ok {
+		return "", nil
+	}
+	delete(opts, "name")
+	name, _ := v.(string)
+	if name == "" {
+		return "", fmt.Errorf("config: \"name\" must be a non-empty string")
+	}
+	return name, nil
+}
+
+// UnmarshalJSON reads a store entry with a "type" key and arbitrary options.
+func (s *StoreSpec) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var err error
+	s.Type, s.Options, err = splitType(raw)
+	return err
+}
+
+// UnmarshalYAML reads a store entry with a "type" key and arbitrary options.
+func (s *StoreSpec) UnmarshalYAML(node *yaml.Node) error {
+	var raw map[string]interface{}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	var err error
+	s.Type, s.Options, err = splitType(raw)
+	return err
+}
+
+// UnmarshalJSON reads a strategy entry with a "type" key, an optional "name", and arbitrary options.
+func (s *StrategySpec) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	return s.split(raw)
+}
+
+// UnmarshalYAML reads a strategy entry with a "type" key, an optional "name", and arbitrary options.
+func (s *StrategySpec) UnmarshalYAML(node *yaml.Node) error {
+	var raw map[string]interface{}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	return s.split(raw)
+}
+
+// split fills the spec from a raw strategy entry.
+func (s *StrategySpec) split(raw map[string]interface{}) error {
+	var err error
+	if s.Type, s.Options, err = splitType(raw); err != nil {
+		return err
+	}
+	s.Name, err = splitName(s.Options)
+	return err
+}
+
+// ParseJSON reads a Spec from JSON.
+func ParseJSON(r io.Reader) (*Spec, error) {
+	spec := &Spec{}
+	if err := json.NewDecoder(r).Decode(spec); err != nil {
+		return nil, fmt.Errorf("config: decode json: %w", err)
+	}
+	return spec, nil
+}
+
+// ParseYAML reads a Spec from YAML.
+func ParseYAML(r io.Reader) (*Spec, error) {
+	spec := &Spec{}
+	if err := yaml.NewDecoder(r).Decode(spec); err != nil {
+		return nil, fmt.Errorf("config: decode yaml: %w", err)
+	}
+	return spec, nil
+}
+
+// ParseFile reads a Spec, choosing the format from the extension (.json, .yaml, .yml).
+func ParseFile(path string) (*Spec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ParseJSON(f)
+	case ".yaml", ".yml":
+		return ParseYAML(f)
+	}
+	return nil, fmt.Errorf("config: unsupported file extension %q", filepath.Ext(path))
+}
+
+// Load parses the file at path and builds it.
+func Load(path string) (*Auth, error) {
+	spec, err := ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Build(spec)
+}
+
+// Secret is a sensitive value given inline, via an environment variable, or via a file.
+// In a config file it is either a plain string or an object with one of "value", "env", or "file".
+type Secret struct {
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	Env   string `json:"env,omitempty" yaml:"env,omitempty"`
+	File  string `json:"file,omitempty" yaml:"file,omitempty"`
+}
+
+// UnmarshalJSON accepts a plain string or an object.
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = Secret{Value: str}
+		return nil
+	}
+	type plain Secret
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// Resolve returns the secret's value. Trailing newlines are trimmed from file contents.
+func (s Secret) Resolve() (string, error) {
+	switch {
+	case s.Env != "":
+		v, ok := os.LookupEnv(s.Env)
+		if !ok || v == "" {
+			return "", fmt.Errorf("config: environment variable %s is not set", s.Env)
+		}
+		return v, nil
+	case s.File != "":
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", fmt.Errorf("config: read secret: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case s.Value != "":
+		return s.Value, nil
+	}
+	return "", fmt.Errorf("config: secret has no value, env, or file")
+}
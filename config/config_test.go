@@ -0,0 +1,324 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-ez-auth/config"
+	"go-ez-auth/core"
+	"go-ez-auth/stores"
+)
+
+const yamlConfig = `
+stores:
+  keys:
+    type: apikey
+    keys:
+      - key: {env: CONFIG_TEST_KEY}
+        user: {id: svc, attributes: {roles: [service]}}
+      - key: {file: KEYFILE}
+        user: {id: ops}
+strategies:
+  - type: apikey
+    store: keys
+routes:
+  - path: /api/
+    strategies: [apikey]
+  - path: /api/health
+    public: true
+`
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_YAML(t *testing.T) {
+	t.Setenv("CONFIG_TEST_KEY", "env-key")
+	keyFile := writeFile(t, "key", "file-key\n")
+	path := writeFile(t, "auth.yaml", strings.Replace(yamlConfig, "KEYFILE", keyFile, 1))
+
+	auth, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	defer auth.Close()
+
+	handler := auth.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, ok := core.UserFromContext(r.Context()); ok {
+			w.Write([]byte(u.GetID()))
+		}
+	}))
+	tests := []struct {
+		path, key string
+		code      int
+		body      string
+	}{
+		{"/api/items", "env-key", http.StatusOK, "svc"},
+		{"/api/items", "file-key", http.StatusOK, "ops"},
+		{"/api/items", "", http.StatusUnauthorized, ""},
+		{"/api/health", "", http.StatusOK, ""},
+		{"/public", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.key != "" {
+			req.Header.Set("X-API-Key", tt.key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.code {
+			t.Errorf("%s with key %q: expected %d, got %d", tt.path, tt.key, tt.code, rr.Code)
+		}
+		if tt.code == http.StatusOK && rr.Body.String() != tt.body {
+			t.Errorf("%s with key %q: expected body %q, got %q", tt.path, tt.key, tt.body, rr.Body.String())
+		}
+	}
+}
+
+func TestParseJSON_Build(t *testing.T) {
+	spec, err := config.ParseJSON(strings.NewReader(`{
+		"stores": {"users": {"type": "memory", "users": [{"id": "u1"}]}},
+		"strategies": [{"type": "jwt", "signing_key": "secret", "store": "users"}],
+		"routes": [{"path": "/", "strategies": ["jwt"], "chain_mode": "continue_on_invalid"}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseJSON: %v", err)
+	}
+	auth, err := config.Build(spec)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	defer auth.Close()
+	if got := auth.Authenticator.Strategies(); len(got) != 1 || got[0] != "jwt" {
+		t.Errorf("expected [jwt], got %v", got)
+	}
+	if _, err := auth.Stores["users"].FindUserByID(context.Background(), "u1"); err != nil {
+		t.Errorf("expected u1 in users store, got %v", err)
+	}
+}
+
+func TestBuild_APIKeyDigests(t *testing.T) {
+	issuer := stores.NewAPIKeyStore(nil)
+	key, _ := issuer.Issue(context.Background(), &stores.User{ID: "ops"})
+	id, digest, _ := issuer.Digest(key)
+	spec, err := config.ParseJSON(strings.NewReader(`{
+		"stores": {"keys": {"type": "apikey", "keys": [{"id": "` + id + `", "digest": "` + digest + `", "user": {"id": "ops"}}]}}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseJSON: %v", err)
+	}
+	auth, err := config.Build(spec)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	defer auth.Close()
+	if u, err := auth.Stores["keys"].FindUserByCredentials(context.Background(), map[string]interface{}{"id": key}); err != nil || u.GetID() != "ops" {
+		t.Errorf("expected key configured by digest to authenticate, got %v %v", u, err)
+	}
+}
+
+func TestMiddleware_SegmentBoundary(t *testing.T) {
+	spec, err := config.ParseYAML(strings.NewReader(`
+stores:
+  keys: {type: apikey, keys: [{key: k, user: {id: svc}}]}
+strategies:
+  - {type: apikey, store: keys}
+routes:
+  - {path: /, strategies: [apikey]}
+  - {path: /pub, public: true}
+`))
+	if err != nil {
+		t.Fatalf("ParseYAML: %v", err)
+	}
+	auth, err := config.Build(spec)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	defer auth.Close()
+	handler := auth.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for path, code := range map[string]int{
+		"/pub":       http.StatusOK,
+		"/pub/x":     http.StatusOK,
+		"/publish":   http.StatusUnauthorized,
+		"/pub-admin": http.StatusUnauthorized,
+		// Non-canonical paths are redirected, not authenticated for the route they resolve to.
+		"/api/../pub/x": http.StatusTemporaryRedirect,
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != code {
+			t.Errorf("%s: expected %d, got %d", path, code, rr.Code)
+		}
+	}
+}
+
+type customStrategy struct{}
+
+func (customStrategy) Name() string { return "custom" }
+func (customStrategy) Setup() error { return nil }
+func (customStrategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	return &stores.User{ID: "custom"}, nil
+}
+
+func TestRegisterStrategyFactory(t *testing.T) {
+	var gotRealm string
+	config.RegisterStrategyFactory("custom", func(opts config.Options, env *config.Env) (core.Strategy, error) {
+		var o struct {
+			Realm string `json:"realm"`
+		}
+		if err := opts.Decode(&o); err != nil {
+			return nil, err
+		}
+		gotRealm = o.Realm
+		return customStrategy{}, nil
+	})
+	spec, err := config.ParseYAML(strings.NewReader("strategies:\n  - type: custom\n    realm: internal\n"))
+	if err != nil {
+		t.Fatalf("ParseYAML: %v", err)
+	}
+	auth, err := config.Build(spec)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if gotRealm != "internal" {
+		t.Errorf("expected realm option to be passed, got %q", gotRealm)
+	}
+	if _, ok := auth.Authenticator.Strategy("custom"); !ok {
+		t.Error("expected custom strategy to be registered")
+	}
+}
+
+func TestBuild_OAuth2NumericID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/token":
+			w.Write([]byte(`{"access_token": "t", "token_type": "Bearer"}`))
+		case "/userinfo":
+			w.Write([]byte(`{"id": 123456789}`))
+		}
+	}))
+	defer srv.Close()
+	spec, err := config.ParseJSON(strings.NewReader(`{"strategies": [{"type": "oauth2", "client_id": "c", "client_secret": "s",
+		"token_url": "` + srv.URL + `/token", "userinfo_url": "` + srv.URL + `/userinfo", "id_field": "id"}]}`))
+	if err != nil {
+		t.Fatalf("ParseJSON: %v", err)
+	}
+	auth, err := config.Build(spec)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	defer auth.Close()
+	strat, _ := auth.Authenticator.Strategy("oauth2")
+	u, err := strat.Authenticate(context.Background(), httptest.NewRequest("GET", "/callback?code=c", nil))
+	if err != nil || u.GetID() != "123456789" {
+		t.Errorf("expected numeric ID to be formatted in full, got %v %v", u, err)
+	}
+}
+
+func TestBuild_StrategyNames(t *testing.T) {
+	spec, err := config.ParseYAML(strings.NewReader(`
+strategies:
+  - type: jwt
+    signing_key: a
+  - type: jwt
+    name: partner-jwt
+    signing_key: b
+routes:
+  - path: /partner/
+    strategies: [partner-jwt]
+`))
+	if err != nil {
+		t.Fatalf("ParseYAML: %v", err)
+	}
+	auth, err := config.Build(spec)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	defer auth.Close()
+	for _, name := range []string{"jwt", "partner-jwt"} {
+		if _, ok := auth.Authenticator.Strategy(name); !ok {
+			t.Errorf("expected strategy %q to be registered", name)
+		}
+	}
+}
+
+// closingStore records whether it was closed.
+type closingStore struct {
+	core.UserStore
+	closed *bool
+}
+
+func (s closingStore) Close() error {
+	*s.closed = true
+	return nil
+}
+
+func TestBuild_ClosesStores(t *testing.T) {
+	var closed []*bool
+	config.RegisterStoreFactory("closing", func(opts config.Options, env *config.Env) (core.UserStore, error) {
+		closed = append(closed, new(bool))
+		return closingStore{UserStore: stores.NewInMemoryUserStore(), closed: closed[len(closed)-1]}, nil
+	})
+	build := func(src string) (*config.Auth, error) {
+		spec, err := config.ParseJSON(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("ParseJSON: %v", err)
+		}
+		return config.Build(spec)
+	}
+
+	if _, err := build(`{"stores": {"s": {"type": "closing"}}, "strategies": [{"type": "nope"}]}`); err == nil {
+		t.Fatal("expected error")
+	}
+	if !*closed[0] {
+		t.Error("expected a failed Build to close the stores it built")
+	}
+	auth, err := build(`{"stores": {"s": {"type": "closing"}}}`)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if err := auth.Close(); err != nil || !*closed[1] {
+		t.Errorf("expected Close to close the stores, got %v", err)
+	}
+}
+
+func TestBuild_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unknown strategy type": `{"strategies": [{"type": "nope"}]}`,
+		"unknown store":         `{"strategies": [{"type": "local", "store": "missing"}]}`,
+		"unknown option":        `{"stores": {"s": {"type": "memory", "userz": []}}}`,
+		"unset env secret":      `{"strategies": [{"type": "jwt", "signing_key": {"env": "CONFIG_TEST_UNSET"}}]}`,
+		"invalid config":        `{"strategies": [{"type": "jwt", "signing_key": "k", "signing_method": "RS256"}]}`,
+		"unknown route name":    `{"routes": [{"path": "/", "strategies": ["jwt"]}]}`,
+		"duplicate strategy":    `{"strategies": [{"type": "jwt", "signing_key": "a"}, {"type": "jwt", "signing_key": "b"}]}`,
+		"empty strategy name":   `{"strategies": [{"type": "jwt", "name": "", "signing_key": "a"}]}`,
+		"bad chain mode":        `{"routes": [{"path": "/", "chain_mode": "sometimes"}]}`,
+		"relative route path":   `{"routes": [{"path": "api/"}]}`,
+		"duplicate route path":  `{"routes": [{"path": "/api", "public": true}, {"path": "/api/", "public": true}]}`,
+	}
+	for name, src := range tests {
+		spec, err := config.ParseJSON(strings.NewReader(src))
+		if err == nil {
+			_, err = config.Build(spec)
+		}
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	spec, _ := config.ParseJSON(strings.NewReader(tests["invalid config"]))
+	if _, err := config.Build(spec); !errors.Is(err, core.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
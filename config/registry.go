@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"go-ez-auth/core"
+)
+
+// Env gives factories access to the stores built so far.
+type Env struct {
+	stores map[string]core.UserStore
+}
+
+// Store returns the store declared under name.
+func (e *Env) Store(name string) (core.UserStore, error) {
+	if name == "" {
+		return nil, fmt.Errorf("config: store name is required")
+	}
+	s, ok := e.stores[name]
+	if !ok {
+		return nil, fmt.Errorf("config: unknown store %q", name)
+	}
+	return s, nil
+}
+
+// StrategyFactory builds a strategy from its options.
+type StrategyFactory func(opts Options, env *Env) (core.Strategy, error)
+
+// StoreFactory builds a user store from its options.
+type StoreFactory func(opts Options, env *Env) (core.UserStore, error)
+
+var (
+	registryMu        sync.RWMutex
+	strategyFactories = make(map[string]StrategyFactory)
+	storeFactories    = make(map[string]StoreFactory)
+)
+
+// RegisterStrategyFactory makes a strategy type available to config files, replacing any
+// factory registered under the same type.
+func RegisterStrategyFactory(typ string, f StrategyFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	strategyFactories[typ] = f
+}
+
+// RegisterStoreFactory makes a store type available to config files, replacing any
+// factory registered under the same type.
+func RegisterStoreFactory(typ string, f StoreFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	storeFactories[typ] = f
+}
+
+// StrategyTypes returns the registered strategy types in sorted order.
+func StrategyTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(strategyFactories))
+	for t := range strategyFactories {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func strategyFactory(typ string) (StrategyFactory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	f, ok := strategyFactories[typ]
+	return f, ok
+}
+
+func storeFactory(typ string) (StoreFactory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	f, ok := storeFactories[typ]
+	return f, ok
+}
@@ -0,0 +1,74 @@
+// Package audit provides sinks that record go-ez-auth events for later review.
+// A Sink writes each core.Event as one JSON line to an io.Writer, such as a RotatingFile:
+//
+//	sink := audit.NewSink(file)
+//	auth.Subscribe(sink.Handle)
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"go-ez-auth/core"
+)
+
+// Record is the JSON representation of an event written by a Sink.
+type Record struct {
+	Time         time.Time      `json:"time"`
+	Type         core.EventType `json:"type"`
+	Strategy     string         `json:"strategy,omitempty"`
+	UserID       string         `json:"user_id,omitempty"`
+	ActorID      string         `json:"actor_id,omitempty"`     // real user when UserID was impersonated
+	Impersonated bool           `json:"impersonated,omitempty"` // set whenever ActorID is
+	RemoteIP     string         `json:"remote_ip,omitempty"`
+	UserAgent    string         `json:"user_agent,omitempty"`
+	Reason       core.Reason    `json:"reason,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// NewRecord converts an event into a Record.
+func NewRecord(e core.Event) Record {
+	rec := Record{
+		Time:      e.Time.UTC(),
+		Type:      e.Type,
+		Strategy:  e.Strategy,
+		UserID:    e.UserID,
+		RemoteIP:  e.RemoteIP,
+		UserAgent: e.UserAgent,
+		Reason:    e.Reason,
+	}
+	if e.ActorID != "" {
+		rec.ActorID, rec.Impersonated = e.ActorID, true
+	}
+	if e.Err != nil {
+		rec.Error = e.Err.Error()
+	}
+	return rec
+}
+
+// Sink writes events as JSON lines. It is safe for concurrent use.
+type Sink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	// OnError, if set, receives write errors, which are otherwise dropped so that
+	// auditing never fails a request.
+	OnError func(err error)
+}
+
+// NewSink creates a Sink writing to w.
+func NewSink(w io.Writer) *Sink {
+	return &Sink{enc: json.NewEncoder(w)}
+}
+
+// Handle writes e as a JSON line. Its signature matches core.EventHandler.
+func (s *Sink) Handle(ctx context.Context, e core.Event) {
+	s.mu.Lock()
+	err := s.enc.Encode(NewRecord(e))
+	s.mu.Unlock()
+	if err != nil && s.OnError != nil {
+		s.OnError(err)
+	}
+}
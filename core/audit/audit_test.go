@@ -0,0 +1,68 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-ez-auth/core"
+	"go-ez-auth/core/audit"
+)
+
+func TestSink_WritesJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	sink := audit.NewSink(&buf)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.7:5555"
+	req.Header.Set("User-Agent", "curl/8")
+	e := core.NewEvent(core.EventLoginFailure, req)
+	e.Strategy, e.Reason, e.Err = "jwt", core.ReasonExpired, errors.New("token is expired")
+	sink.Handle(context.Background(), e)
+	sink.Handle(context.Background(), core.NewEvent(core.EventLogout, nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), buf.String())
+	}
+	var rec audit.Record
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("invalid JSON line: %v", err)
+	}
+	if rec.Type != core.EventLoginFailure || rec.Strategy != "jwt" || rec.RemoteIP != "192.0.2.7" ||
+		rec.UserAgent != "curl/8" || rec.Reason != core.ReasonExpired || rec.Error != "token is expired" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	rf, err := audit.NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("NewRotatingFile: %v", err)
+	}
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := rf.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	want := map[string]string{"": "dddddddd\n", ".1": "cccccccc\n", ".2": "bbbbbbbb\n"}
+	for suffix, content := range want {
+		got, err := os.ReadFile(path + suffix)
+		if err != nil || string(got) != content {
+			t.Errorf("%s: expected %q, got %q %v", path+suffix, content, got, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("expected at most 2 backups")
+	}
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Authenticator owns a set of authentication strategies and runs them against requests.
+// It is safe for concurrent use, so strategies may be registered while requests are served.
+// Independent services in one binary should each create their own Authenticator.
+type Authenticator struct {
+	mu          sync.RWMutex
+	strategies  map[string]Strategy
+	subscribers []subscription
+	nextSubID   uint64
+	metrics     Metrics
+}
+
+// NewAuthenticator creates an Authenticator with no registered strategies.
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{strategies: make(map[string]Strategy)}
+}
+
+// Register calls the strategy's Setup and, if it succeeds, adds the strategy under its Name,
+// replacing and closing any strategy with the same name. A strategy whose Setup fails is not
+// registered.
+func (a *Authenticator) Register(s Strategy) error {
+	if err := s.Setup(); err != nil {
+		return fmt.Errorf("core: setup strategy %q: %w", s.Name(), err)
+	}
+	a.mu.Lock()
+	old, replaced := a.strategies[s.Name()]
+	a.strategies[s.Name()] = s
+	a.mu.Unlock()
+	if !replaced || sameStrategy(old, s) {
+		return nil
+	}
+	return closeStrategy(old)
+}
+
+// sameStrategy reports whether a and b are the same pointer, as when a strategy is registered
+// twice. Other strategies are never considered the same, since they may not be comparable.
+func sameStrategy(a, b Strategy) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	return va.Kind() == reflect.Pointer && va.Type() == vb.Type() && va.Pointer() == vb.Pointer()
+}
+
+// Unregister removes the named strategy, if present, and closes it if it implements io.Closer.
+func (a *Authenticator) Unregister(name string) error {
+	a.mu.Lock()
+	s, ok := a.strategies[name]
+	delete(a.strategies, name)
+	a.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return closeStrategy(s)
+}
+
+// Close unregisters every strategy and closes those that implement io.Closer,
+// such as strategies running background key refreshers or caches.
+func (a *Authenticator) Close() error {
+	a.mu.Lock()
+	strategies := a.strategies
+	a.strategies = make(map[string]Strategy)
+	a.mu.Unlock()
+	var errs []error
+	for _, s := range strategies {
+		if err := closeStrategy(s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeStrategy closes s if it implements io.Closer.
+func closeStrategy(s Strategy) error {
+	c, ok := s.(io.Closer)
+	if !ok {
+		return nil
+	}
+	if err := c.Close(); err != nil {
+		return fmt.Errorf("core: close strategy %q: %w", s.Name(), err)
+	}
+	return nil
+}
+
+// Strategy retrieves a registered strategy by name.
+func (a *Authenticator) Strategy(name string) (Strategy, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	s, ok := a.strategies[name]
+	return s, ok
+}
+
+// Strategies returns the names of all registered strategies in sorted order.
+func (a *Authenticator) Strategies() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, 0, len(a.strategies))
+	for name := range a.strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ChainMode controls how the Authenticator proceeds after a strategy fails.
+type ChainMode int
+
+const (
+	// StopOnInvalid moves on to the next strategy only when the current one found no credential.
+	// A credential that is present but invalid (e.g. an expired JWT) ends the chain and is reported.
+	// A bare ErrUnauthorized counts as no credential, so strategies predating AuthError fall through.
+	StopOnInvalid ChainMode = iota
+	// ContinueOnInvalid tries every strategy until one succeeds, regardless of why the others failed.
+	ContinueOnInvalid
+)
+
+// Authenticate runs the named strategies with StopOnInvalid semantics and returns the first successful user.
+func (a *Authenticator) Authenticate(r *http.Request, strategyNames ...string) (User, error) {
+	res, err := a.AuthenticateChain(r, StopOnInvalid, strategyNames...)
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
+
+// AuthenticateChain tries each named strategy in order according to mode and returns the result of
+// the first one that succeeds. Names that are not registered are skipped. If no strategy succeeds,
+// the returned *ChainError records the failure of every strategy that was tried. A failure caused
+// by a credential that was presented is published to subscribers as an EventLoginFailure event;
+// successes and requests without credentials are not, since every request runs the chain.
+// Explicit logins, such as session.Strategy.Login, publish their own EventLoginSuccess. The
+// exception is impersonation: every impersonated request publishes an EventLoginSuccess with
+// ActorID set, so that audit logs record what was done on the target's behalf.
+func (a *Authenticator) AuthenticateChain(r *http.Request, mode ChainMode, strategyNames ...string) (*AuthResult, error) {
+	chainErr := &ChainError{}
+	for _, name := range strategyNames {
+		strat, ok := a.Strategy(name)
+		if !ok {
+			continue
+		}
+		start := time.Now()
+		res, err := authenticateResult(name, strat, r)
+		if err == nil {
+			a.observe(name, start, nil)
+			if res.Actor != nil {
+				e := NewEvent(EventLoginSuccess, r)
+				e.Strategy, e.UserID, e.ActorID = name, res.User.GetID(), res.Actor.GetID()
+				a.Emit(r.Context(), e)
+			}
+			return res, nil
+		}
+		authErr := asAuthError(name, err)
+		a.observe(name, start, authErr)
+		chainErr.Errors = append(chainErr.Errors, authErr)
+		if mode == StopOnInvalid && authErr.CredentialsPresent() {
+			break
+		}
+	}
+	chainErr.Challenges = a.challenges(r, chainErr, strategyNames)
+	a.emitFailure(r, chainErr)
+	return nil, chainErr
+}
+
+// emitFailure publishes an EventLoginFailure attributed to the first strategy that rejected a
+// present credential. Chains in which no credential was presented publish nothing.
+func (a *Authenticator) emitFailure(r *http.Request, chainErr *ChainError) {
+	cause := chainErr.Invalid()
+	if cause == nil {
+		return
+	}
+	e := NewEvent(EventLoginFailure, r)
+	e.Strategy, e.Reason, e.Err = cause.Strategy, cause.Reason, chainErr
+	e.UserID, e.ActorID = cause.UserID, cause.ActorID
+	a.Emit(r.Context(), e)
+}
+
+// asAuthError converts a strategy error into an *AuthError, preserving one if already present.
+// A bare ErrUnauthorized, as returned by strategies written before AuthError existed, says
+// nothing about whether a credential was present, so it is treated as a missing credential
+// and lets the chain continue as it used to.
+func asAuthError(strategy string, err error) *AuthError {
+	var ae *AuthError
+	if errors.As(err, &ae) {
+		return ae
+	}
+	if errors.Is(err, ErrNoCredentials) || errors.Is(err, ErrUnauthorized) {
+		return NewAuthError(strategy, ReasonMissingCredentials, err)
+	}
+	return NewAuthError(strategy, ReasonInvalidCredentials, err)
+}
+
+// defaultAuthenticator backs the package-level registry functions.
+var defaultAuthenticator = NewAuthenticator()
+
+// DefaultAuthenticator returns the shared Authenticator used by RegisterStrategy and GetStrategy.
+func DefaultAuthenticator() *Authenticator {
+	return defaultAuthenticator
+}
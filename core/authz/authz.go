@@ -0,0 +1,206 @@
+// Package authz provides role and permission based authorization on top of core.User.
+// Roles form a hierarchy through Inherits, permissions are colon-separated strings
+// (e.g. "articles:write") that may use "*" wildcards, and a RoleResolver decides which
+// roles a user holds, either from the user's attributes or from a RoleStore.
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go-ez-auth/core"
+)
+
+// ErrUnknownRole is returned when a role references an inherited role that is not defined.
+var ErrUnknownRole = errors.New("unknown role")
+
+// Role is a named set of permissions that may inherit the permissions of other roles.
+type Role struct {
+	Name        string
+	Permissions []string // e.g. "articles:read", "articles:*", "*"
+	Inherits    []string // names of roles whose permissions this role also grants
+}
+
+// Model holds role definitions and answers role and permission questions.
+// It is safe for concurrent use.
+type Model struct {
+	mu    sync.RWMutex
+	roles map[string]Role
+}
+
+// NewModel creates a Model from the given roles.
+func NewModel(roles ...Role) (*Model, error) {
+	m := &Model{roles: make(map[string]Role)}
+	for _, r := range roles {
+		m.roles[r.Name] = r
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AddRole adds or replaces a role definition.
+func (m *Model) AddRole(r Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev, existed := m.roles[r.Name]
+	m.roles[r.Name] = r
+	if err := m.validateLocked(); err != nil {
+		if existed {
+			m.roles[r.Name] = prev
+		} else {
+			delete(m.roles, r.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// validate checks that every inherited role is defined.
+func (m *Model) validate() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.validateLocked()
+}
+
+func (m *Model) validateLocked() error {
+	for _, r := range m.roles {
+		for _, parent := range r.Inherits {
+			if _, ok := m.roles[parent]; !ok {
+				return fmt.Errorf("authz: role %q inherits %q: %w", r.Name, parent, ErrUnknownRole)
+			}
+		}
+	}
+	return nil
+}
+
+// expand returns the given roles plus every role they inherit, directly or transitively.
+// Cycles in the hierarchy are tolerated.
+func (m *Model) expand(roles []string) map[string]struct{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	seen := make(map[string]struct{})
+	stack := append([]string(nil), roles...)
+	for len(stack) > 0 {
+		name := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		stack = append(stack, m.roles[name].Inherits...)
+	}
+	return seen
+}
+
+// HasRole reports whether the held roles include role, directly or through inheritance.
+func (m *Model) HasRole(held []string, role string) bool {
+	_, ok := m.expand(held)[role]
+	return ok
+}
+
+// Permissions returns every permission granted by the held roles, including inherited ones.
+func (m *Model) Permissions(held []string) []string {
+	expanded := m.expand(held)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var perms []string
+	for name := range expanded {
+		perms = append(perms, m.roles[name].Permissions...)
+	}
+	return perms
+}
+
+// Can reports whether the held roles grant permission.
+func (m *Model) Can(held []string, permission string) bool {
+	for _, granted := range m.Permissions(held) {
+		if MatchPermission(granted, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPermission reports whether the granted permission pattern covers permission.
+// Patterns are compared segment by segment on ":"; a "*" segment matches any single
+// segment, and a trailing "*" matches all remaining segments.
+func MatchPermission(granted, permission string) bool {
+	g := strings.Split(granted, ":")
+	p := strings.Split(permission, ":")
+	for i, seg := range g {
+		if seg == "*" && i == len(g)-1 {
+			return true
+		}
+		if i >= len(p) {
+			return false
+		}
+		if seg != "*" && seg != p[i] {
+			return false
+		}
+	}
+	return len(g) == len(p)
+}
+
+// Config holds settings for an Authorizer.
+type Config struct {
+	Model    *Model       // role definitions
+	Resolver RoleResolver // source of a user's roles; defaults to AttributeResolver{}
+}
+
+// Authorizer checks roles and permissions for authenticated users.
+type Authorizer struct {
+	config Config
+}
+
+// New creates an Authorizer from Config.
+func New(config Config) *Authorizer {
+	if config.Model == nil {
+		config.Model = &Model{roles: make(map[string]Role)}
+	}
+	if config.Resolver == nil {
+		config.Resolver = AttributeResolver{}
+	}
+	return &Authorizer{config: config}
+}
+
+// Model returns the Authorizer's role model.
+func (a *Authorizer) Model() *Model {
+	return a.config.Model
+}
+
+// Roles returns the roles held by user.
+func (a *Authorizer) Roles(ctx context.Context, user core.User) ([]string, error) {
+	return a.config.Resolver.Roles(ctx, user)
+}
+
+// HasAnyRole reports whether user holds at least one of roles.
+func (a *Authorizer) HasAnyRole(ctx context.Context, user core.User, roles ...string) (bool, error) {
+	held, err := a.Roles(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if a.config.Model.HasRole(held, role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HasAllPermissions reports whether user is granted every one of permissions.
+func (a *Authorizer) HasAllPermissions(ctx context.Context, user core.User, permissions ...string) (bool, error) {
+	held, err := a.Roles(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	for _, perm := range permissions {
+		if !a.config.Model.Can(held, perm) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
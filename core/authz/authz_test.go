@@ -0,0 +1,119 @@
+package authz_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-ez-auth/core/authz"
+)
+
+type dummyUser struct {
+	id    string
+	attrs map[string]interface{}
+}
+
+func (d dummyUser) GetID() string                         { return d.id }
+func (d dummyUser) GetAttributes() map[string]interface{} { return d.attrs }
+
+func newModel(t *testing.T) *authz.Model {
+	m, err := authz.NewModel(
+		authz.Role{Name: "viewer", Permissions: []string{"articles:read"}},
+		authz.Role{Name: "editor", Permissions: []string{"articles:*"}, Inherits: []string{"viewer"}},
+		authz.Role{Name: "admin", Permissions: []string{"users:*:manage"}, Inherits: []string{"editor"}},
+	)
+	if err != nil {
+		t.Fatalf("NewModel: %v", err)
+	}
+	return m
+}
+
+func TestMatchPermission(t *testing.T) {
+	cases := []struct {
+		granted, perm string
+		want          bool
+	}{
+		{"articles:read", "articles:read", true},
+		{"articles:read", "articles:write", false},
+		{"articles:*", "articles:write", true},
+		{"articles:*", "articles:comments:write", true},
+		{"*", "anything:at:all", true},
+		{"users:*:manage", "users:42:manage", true},
+		{"users:*:manage", "users:42:delete", false},
+		{"users:*:manage", "users:42", false},
+		{"articles", "articles:read", false},
+	}
+	for _, tc := range cases {
+		if got := authz.MatchPermission(tc.granted, tc.perm); got != tc.want {
+			t.Errorf("MatchPermission(%q, %q) = %v, want %v", tc.granted, tc.perm, got, tc.want)
+		}
+	}
+}
+
+func TestModel_Hierarchy(t *testing.T) {
+	m := newModel(t)
+	if !m.HasRole([]string{"admin"}, "viewer") {
+		t.Error("expected admin to inherit viewer")
+	}
+	if m.HasRole([]string{"viewer"}, "editor") {
+		t.Error("expected viewer not to hold editor")
+	}
+	if !m.Can([]string{"admin"}, "articles:delete") {
+		t.Error("expected admin to inherit articles:*")
+	}
+	if m.Can([]string{"viewer"}, "articles:write") {
+		t.Error("expected viewer not to write articles")
+	}
+}
+
+func TestModel_UnknownParent(t *testing.T) {
+	_, err := authz.NewModel(authz.Role{Name: "a", Inherits: []string{"missing"}})
+	if !errors.Is(err, authz.ErrUnknownRole) {
+		t.Errorf("expected ErrUnknownRole, got %v", err)
+	}
+	m := newModel(t)
+	if err := m.AddRole(authz.Role{Name: "viewer", Inherits: []string{"missing"}}); !errors.Is(err, authz.ErrUnknownRole) {
+		t.Errorf("expected ErrUnknownRole, got %v", err)
+	}
+	if !m.Can([]string{"viewer"}, "articles:read") {
+		t.Error("expected failed AddRole to keep the previous definition")
+	}
+}
+
+func TestAuthorizer_AttributeResolver(t *testing.T) {
+	az := authz.New(authz.Config{Model: newModel(t)})
+	ctx := context.Background()
+	cases := []interface{}{
+		[]string{"editor"},
+		[]interface{}{"editor"},
+		"guest, editor",
+	}
+	for _, roles := range cases {
+		user := dummyUser{"u1", map[string]interface{}{"roles": roles}}
+		if ok, err := az.HasAnyRole(ctx, user, "viewer"); err != nil || !ok {
+			t.Errorf("roles %v: expected viewer, got %v %v", roles, ok, err)
+		}
+		if ok, _ := az.HasAllPermissions(ctx, user, "articles:read", "articles:write"); !ok {
+			t.Errorf("roles %v: expected article permissions", roles)
+		}
+		if ok, _ := az.HasAllPermissions(ctx, user, "articles:read", "users:1:manage"); ok {
+			t.Errorf("roles %v: expected users:1:manage to be denied", roles)
+		}
+	}
+}
+
+func TestAuthorizer_StoreResolver(t *testing.T) {
+	store := authz.NewInMemoryRoleStore(map[string][]string{"u1": {"admin"}})
+	az := authz.New(authz.Config{Model: newModel(t), Resolver: authz.StoreResolver{Store: store}})
+	ctx := context.Background()
+	if ok, _ := az.HasAnyRole(ctx, dummyUser{id: "u1"}, "editor"); !ok {
+		t.Error("expected u1 to hold editor via admin")
+	}
+	if ok, _ := az.HasAnyRole(ctx, dummyUser{id: "u2"}, "viewer"); ok {
+		t.Error("expected u2 to hold no roles")
+	}
+	store.Assign("u2", "viewer")
+	if ok, _ := az.HasAnyRole(ctx, dummyUser{id: "u2"}, "viewer"); !ok {
+		t.Error("expected u2 to hold viewer after Assign")
+	}
+}
@@ -0,0 +1,102 @@
+package authz
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"go-ez-auth/core"
+)
+
+// RoleResolver determines the roles held by a user.
+type RoleResolver interface {
+	Roles(ctx context.Context, user core.User) ([]string, error)
+}
+
+// RoleResolverFunc adapts a function to the RoleResolver interface.
+type RoleResolverFunc func(ctx context.Context, user core.User) ([]string, error)
+
+// Roles calls f(ctx, user).
+func (f RoleResolverFunc) Roles(ctx context.Context, user core.User) ([]string, error) {
+	return f(ctx, user)
+}
+
+// AttributeResolver reads roles from a user attribute.
+// The attribute may hold a []string, a []interface{} of strings, or a comma-separated string.
+type AttributeResolver struct {
+	Attribute string // attribute name; defaults to "roles"
+}
+
+// Roles returns the roles listed in the user's attribute.
+func (r AttributeResolver) Roles(ctx context.Context, user core.User) ([]string, error) {
+	name := r.Attribute
+	if name == "" {
+		name = "roles"
+	}
+	switch v := user.GetAttributes()[name].(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		roles := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles, nil
+	case string:
+		var roles []string
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				roles = append(roles, s)
+			}
+		}
+		return roles, nil
+	default:
+		return nil, nil
+	}
+}
+
+// RoleStore looks up the roles assigned to a user ID.
+type RoleStore interface {
+	RolesForUser(ctx context.Context, userID string) ([]string, error)
+}
+
+// StoreResolver resolves roles through a RoleStore.
+type StoreResolver struct {
+	Store RoleStore
+}
+
+// Roles returns the roles the store assigns to the user's ID.
+func (r StoreResolver) Roles(ctx context.Context, user core.User) ([]string, error) {
+	return r.Store.RolesForUser(ctx, user.GetID())
+}
+
+// InMemoryRoleStore is a RoleStore backed by a map. It is safe for concurrent use.
+type InMemoryRoleStore struct {
+	mu    sync.RWMutex
+	roles map[string][]string
+}
+
+// NewInMemoryRoleStore creates a store with the given userID->roles assignments.
+func NewInMemoryRoleStore(assignments map[string][]string) *InMemoryRoleStore {
+	m := make(map[string][]string, len(assignments))
+	for id, roles := range assignments {
+		m[id] = append([]string(nil), roles...)
+	}
+	return &InMemoryRoleStore{roles: m}
+}
+
+// Assign replaces the roles assigned to userID.
+func (s *InMemoryRoleStore) Assign(userID string, roles ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roles[userID] = append([]string(nil), roles...)
+}
+
+// RolesForUser returns the roles assigned to userID; unknown users have no roles.
+func (s *InMemoryRoleStore) RolesForUser(ctx context.Context, userID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.roles[userID]...), nil
+}
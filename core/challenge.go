@@ -0,0 +1,66 @@
+package core
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Challenger is implemented by strategies that can tell clients how to authenticate. When a
+// chain fails, the Authenticator collects the challenge of every strategy in it into
+// ChainError.Challenges, and the middleware adapters send them as WWW-Authenticate headers.
+type Challenger interface {
+	// Challenge returns a WWW-Authenticate challenge such as `Basic realm="api"`. err is the
+	// strategy's failure for this request, or nil if the strategy was not tried.
+	Challenge(r *http.Request, err *AuthError) string
+}
+
+// Challenge formats a WWW-Authenticate challenge from a scheme and name/value parameter pairs,
+// quoting each value. Parameters with an empty value are omitted:
+//
+//	Challenge("Bearer", "realm", "api", "error", "invalid_token") // Bearer realm="api", error="invalid_token"
+func Challenge(scheme string, params ...string) string {
+	var b strings.Builder
+	b.WriteString(scheme)
+	sep := " "
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i+1] == "" {
+			continue
+		}
+		b.WriteString(sep)
+		b.WriteString(params[i])
+		b.WriteString(`="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(params[i+1]))
+		b.WriteString(`"`)
+		sep = ", "
+	}
+	return b.String()
+}
+
+// challenges collects the distinct challenges of the named strategies that implement Challenger,
+// passing each the failure it reported in chainErr, if any.
+func (a *Authenticator) challenges(r *http.Request, chainErr *ChainError, strategyNames []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, name := range strategyNames {
+		strat, ok := a.Strategy(name)
+		if !ok {
+			continue
+		}
+		c, ok := strat.(Challenger)
+		if !ok {
+			continue
+		}
+		var failure *AuthError
+		for _, e := range chainErr.Errors {
+			if e.Strategy == name {
+				failure = e
+				break
+			}
+		}
+		if ch := c.Challenge(r, failure); ch != "" && !seen[ch] {
+			seen[ch] = true
+			out = append(out, ch)
+		}
+	}
+	return out
+}
@@ -0,0 +1,333 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// compositeName builds a composite strategy name such as "all_of(mtls,jwt)".
+func compositeName(kind string, strategies []Strategy) string {
+	names := make([]string, len(strategies))
+	for i, s := range strategies {
+		names[i] = s.Name()
+	}
+	return kind + "(" + strings.Join(names, ",") + ")"
+}
+
+// setupAll runs Setup on each child strategy.
+func setupAll(name string, strategies []Strategy) error {
+	if len(strategies) == 0 {
+		return fmt.Errorf("%s: at least one strategy is required: %w", name, ErrInvalidConfig)
+	}
+	for _, s := range strategies {
+		if err := s.Setup(); err != nil {
+			return fmt.Errorf("%s: setup %q: %w", name, s.Name(), err)
+		}
+	}
+	return nil
+}
+
+// closeAll closes each child strategy that implements io.Closer.
+func closeAll(strategies []Strategy) error {
+	var errs []error
+	for _, s := range strategies {
+		if err := closeStrategy(s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// challengeAll joins the distinct challenges of the child strategies that implement Challenger
+// into one WWW-Authenticate value, passing each the failure it reported within err, if any.
+func challengeAll(r *http.Request, err *AuthError, strategies []Strategy) string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, s := range strategies {
+		c, ok := s.(Challenger)
+		if !ok {
+			continue
+		}
+		if ch := c.Challenge(r, childFailure(err, s.Name())); ch != "" && !seen[ch] {
+			seen[ch] = true
+			out = append(out, ch)
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+// childFailure returns the failure of the named child strategy within err, a composite's
+// failure, or nil if that child did not fail.
+func childFailure(err *AuthError, name string) *AuthError {
+	if err == nil {
+		return nil
+	}
+	if err.Strategy == name {
+		return err
+	}
+	var chainErr *ChainError
+	if errors.As(err.Err, &chainErr) {
+		for _, e := range chainErr.Errors {
+			if e.Strategy == name {
+				return e
+			}
+		}
+		return nil
+	}
+	var inner *AuthError
+	if errors.As(err.Err, &inner) {
+		return childFailure(inner, name)
+	}
+	return nil
+}
+
+// AllOfStrategy succeeds only if every child strategy succeeds for the same user.
+type AllOfStrategy struct {
+	strategies []Strategy
+}
+
+// AllOf returns a strategy requiring every given strategy to authenticate the request, and all of
+// them to agree on the user ID. It is named after its children, e.g. "all_of(mtls,jwt)".
+func AllOf(strategies ...Strategy) *AllOfStrategy {
+	return &AllOfStrategy{strategies: strategies}
+}
+
+// Name returns the composite name.
+func (s *AllOfStrategy) Name() string {
+	return compositeName("all_of", s.strategies)
+}
+
+// Setup runs Setup on every child strategy.
+func (s *AllOfStrategy) Setup() error {
+	return setupAll(s.Name(), s.strategies)
+}
+
+// Close closes every child strategy that implements io.Closer.
+func (s *AllOfStrategy) Close() error {
+	return closeAll(s.strategies)
+}
+
+// Challenge returns the challenges of the child strategies that implement Challenger.
+func (s *AllOfStrategy) Challenge(r *http.Request, err *AuthError) string {
+	return challengeAll(r, err, s.strategies)
+}
+
+// Authenticate returns the user every child strategy agreed on.
+func (s *AllOfStrategy) Authenticate(ctx context.Context, r *http.Request) (User, error) {
+	res, err := s.AuthenticateResult(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
+
+// AuthenticateResult runs the child strategies in order and stops at the first failure, reporting
+// it under the composite name with the child's reason. The result lists the methods of every child,
+// the latest AuthTime, and the earliest ExpiresAt.
+func (s *AllOfStrategy) AuthenticateResult(ctx context.Context, r *http.Request) (*AuthResult, error) {
+	name := s.Name()
+	r = r.WithContext(ctx)
+	var combined *AuthResult
+	for _, strat := range s.strategies {
+		res, err := authenticateResult(strat.Name(), strat, r)
+		if err != nil {
+			authErr := asAuthError(strat.Name(), err)
+			return nil, NewAuthError(name, authErr.Reason, authErr)
+		}
+		if combined == nil {
+			combined = &AuthResult{
+				User:         res.User,
+				Strategy:     name,
+				AuthTime:     res.AuthTime,
+				ExpiresAt:    res.ExpiresAt,
+				CredentialID: res.CredentialID,
+				Actor:        res.Actor,
+			}
+		} else if res.User.GetID() != combined.User.GetID() {
+			return nil, NewAuthError(name, ReasonInvalidCredentials,
+				fmt.Errorf("%q authenticated %q but %q authenticated %q",
+					s.strategies[0].Name(), combined.User.GetID(), strat.Name(), res.User.GetID()))
+		}
+		combined.Methods = append(combined.Methods, res.Methods...)
+		if res.AuthTime.After(combined.AuthTime) {
+			combined.AuthTime = res.AuthTime
+		}
+		if !res.ExpiresAt.IsZero() && (combined.ExpiresAt.IsZero() || res.ExpiresAt.Before(combined.ExpiresAt)) {
+			combined.ExpiresAt = res.ExpiresAt
+		}
+	}
+	return combined, nil
+}
+
+// AnyOfStrategy succeeds with the first child strategy that authenticates the request.
+type AnyOfStrategy struct {
+	strategies []Strategy
+	mode       ChainMode
+}
+
+// AnyOf returns a strategy trying the given strategies in order with StopOnInvalid semantics,
+// like Authenticator.Authenticate. It is named after its children, e.g. "any_of(jwt,apikey)".
+func AnyOf(strategies ...Strategy) *AnyOfStrategy {
+	return &AnyOfStrategy{strategies: strategies}
+}
+
+// WithChainMode sets how the strategy proceeds after a child fails and returns s.
+func (s *AnyOfStrategy) WithChainMode(mode ChainMode) *AnyOfStrategy {
+	s.mode = mode
+	return s
+}
+
+// Name returns the composite name.
+func (s *AnyOfStrategy) Name() string {
+	return compositeName("any_of", s.strategies)
+}
+
+// Setup runs Setup on every child strategy.
+func (s *AnyOfStrategy) Setup() error {
+	return setupAll(s.Name(), s.strategies)
+}
+
+// Close closes every child strategy that implements io.Closer.
+func (s *AnyOfStrategy) Close() error {
+	return closeAll(s.strategies)
+}
+
+// Challenge returns the challenges of the child strategies that implement Challenger.
+func (s *AnyOfStrategy) Challenge(r *http.Request, err *AuthError) string {
+	return challengeAll(r, err, s.strategies)
+}
+
+// Authenticate returns the user of the first child strategy that succeeds.
+func (s *AnyOfStrategy) Authenticate(ctx context.Context, r *http.Request) (User, error) {
+	res, err := s.AuthenticateResult(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
+
+// AuthenticateResult returns the result of the first child strategy that succeeds; its Strategy
+// field names that child. On failure the error wraps a *ChainError of the children's failures and
+// reports missing credentials only if no child found one.
+func (s *AnyOfStrategy) AuthenticateResult(ctx context.Context, r *http.Request) (*AuthResult, error) {
+	r = r.WithContext(ctx)
+	chainErr := &ChainError{}
+	for _, strat := range s.strategies {
+		res, err := authenticateResult(strat.Name(), strat, r)
+		if err == nil {
+			return res, nil
+		}
+		authErr := asAuthError(strat.Name(), err)
+		chainErr.Errors = append(chainErr.Errors, authErr)
+		if s.mode == StopOnInvalid && authErr.CredentialsPresent() {
+			break
+		}
+	}
+	reason := ReasonMissingCredentials
+	if invalid := chainErr.Invalid(); invalid != nil {
+		reason = invalid.Reason
+	}
+	return nil, NewAuthError(s.Name(), reason, chainErr)
+}
+
+// WhenStrategy applies a strategy only to requests matching a predicate.
+type WhenStrategy struct {
+	predicate func(*http.Request) bool
+	strategy  Strategy
+}
+
+// When returns a strategy that runs s for requests matching predicate and reports missing
+// credentials for all others, so an Authenticator chain moves on. It is named "when(<s>)".
+func When(predicate func(*http.Request) bool, s Strategy) *WhenStrategy {
+	return &WhenStrategy{predicate: predicate, strategy: s}
+}
+
+// Name returns the composite name.
+func (s *WhenStrategy) Name() string {
+	return compositeName("when", []Strategy{s.strategy})
+}
+
+// Setup runs Setup on the wrapped strategy.
+func (s *WhenStrategy) Setup() error {
+	if s.predicate == nil {
+		return fmt.Errorf("%s: predicate is required: %w", s.Name(), ErrInvalidConfig)
+	}
+	return setupAll(s.Name(), []Strategy{s.strategy})
+}
+
+// Close closes the wrapped strategy if it implements io.Closer.
+func (s *WhenStrategy) Close() error {
+	return closeStrategy(s.strategy)
+}
+
+// Challenge forwards to the wrapped strategy if it implements Challenger.
+func (s *WhenStrategy) Challenge(r *http.Request, err *AuthError) string {
+	return challengeAll(r, err, []Strategy{s.strategy})
+}
+
+// Authenticate runs the wrapped strategy if the request matches.
+func (s *WhenStrategy) Authenticate(ctx context.Context, r *http.Request) (User, error) {
+	res, err := s.AuthenticateResult(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
+
+// AuthenticateResult runs the wrapped strategy if the request matches.
+func (s *WhenStrategy) AuthenticateResult(ctx context.Context, r *http.Request) (*AuthResult, error) {
+	if !s.predicate(r) {
+		return nil, NoCredentialsError(s.Name())
+	}
+	return authenticateResult(s.strategy.Name(), s.strategy, r.WithContext(ctx))
+}
+
+// PathPrefix returns a predicate for When matching requests whose URL path starts with prefix.
+func PathPrefix(prefix string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		return strings.HasPrefix(r.URL.Path, prefix)
+	}
+}
+
+// namedStrategy overrides the name of a strategy.
+type namedStrategy struct {
+	Strategy
+	name string
+}
+
+// Named returns s registered under name instead of s.Name(), e.g. to give a composite a short name
+// or to register two differently configured composites of the same children.
+func Named(name string, s Strategy) Strategy {
+	return &namedStrategy{Strategy: s, name: name}
+}
+
+// Name returns the overriding name.
+func (s *namedStrategy) Name() string {
+	return s.name
+}
+
+// AuthenticateResult runs the wrapped strategy, attributing the result to the overriding name
+// unless the wrapped strategy named a more specific one.
+func (s *namedStrategy) AuthenticateResult(ctx context.Context, r *http.Request) (*AuthResult, error) {
+	res, err := authenticateResult(s.Strategy.Name(), s.Strategy, r.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if res.Strategy == s.Strategy.Name() {
+		res.Strategy = s.name
+	}
+	return res, nil
+}
+
+// Close closes the wrapped strategy if it implements io.Closer.
+func (s *namedStrategy) Close() error {
+	return closeStrategy(s.Strategy)
+}
+
+// Challenge forwards to the wrapped strategy if it implements Challenger.
+func (s *namedStrategy) Challenge(r *http.Request, err *AuthError) string {
+	return challengeAll(r, err, []Strategy{s.Strategy})
+}
@@ -1,5 +1,5 @@
 // Package core defines the foundational interfaces and error types for go-ez-auth.
-// It includes Strategy, User, and UserStore interfaces, plus a strategy registry and common errors.
+// It includes Strategy, User, and UserStore interfaces, plus the Authenticator registry and common errors.
 // Core logic is framework-agnostic and used by middleware adapters.
 
 package core
@@ -11,6 +11,10 @@ import (
 )
 
 // Strategy defines the methods for authentication strategies.
+// Setup is called when the strategy is registered and should validate its configuration,
+// returning an error wrapping ErrInvalidConfig if it is unusable. Strategies holding
+// resources such as background goroutines may also implement io.Closer; Authenticator.Close
+// and Authenticator.Unregister call it.
 type Strategy interface {
 	Name() string
 	Setup() error
@@ -30,41 +34,96 @@ type UserStore interface {
 	FindUserByCredentials(ctx context.Context, criteria map[string]interface{}) (User, error)
 }
 
-// strategyRegistry holds registered authentication strategies.
-var strategyRegistry = make(map[string]Strategy)
+// UserWriter is implemented by user stores that can be modified at runtime, e.g. for registration.
+type UserWriter interface {
+	// CreateUser adds user, failing with ErrUserExists if its ID (or another unique field) is taken.
+	CreateUser(ctx context.Context, user User) error
+	// UpdateUser replaces the user with the same ID, failing with ErrUserNotFound if there is none.
+	UpdateUser(ctx context.Context, user User) error
+	// DeleteUser removes the user with id, failing with ErrUserNotFound if there is none.
+	DeleteUser(ctx context.Context, id string) error
+	// ListUsers returns a page of users ordered by ID.
+	ListUsers(ctx context.Context, opts ListOptions) ([]User, error)
+}
+
+// ListOptions selects a page of users for UserWriter.ListUsers. Pages are keyed by ID, so
+// pass the ID of the last user of one page as After to fetch the next.
+type ListOptions struct {
+	After string // only return users whose ID sorts after After; "" starts from the beginning
+	Limit int    // maximum number of users to return; 0 means no limit
+}
 
-// RegisterStrategy registers a new authentication strategy by name.
-func RegisterStrategy(s Strategy) {
-	strategyRegistry[s.Name()] = s
+// RegisterStrategy sets up and registers a new authentication strategy on the default Authenticator.
+func RegisterStrategy(s Strategy) error {
+	return defaultAuthenticator.Register(s)
 }
 
-// GetStrategy retrieves a registered strategy by name.
+// GetStrategy retrieves a strategy registered on the default Authenticator.
 func GetStrategy(name string) (Strategy, bool) {
-	s, ok := strategyRegistry[name]
-	return s, ok
+	return defaultAuthenticator.Strategy(name)
 }
 
-// ListStrategies returns the names of all registered strategies.
+// ListStrategies returns the names of all strategies registered on the default Authenticator.
 func ListStrategies() []string {
-	names := make([]string, 0, len(strategyRegistry))
-	for name := range strategyRegistry {
-		names = append(names, name)
-	}
-	return names
+	return defaultAuthenticator.Strategies()
 }
 
-// ContextUserKey is the context key for storing the authenticated User.
+// contextKey is the unexported type for context.Context keys defined in this package,
+// which prevents collisions with keys defined elsewhere.
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	resultContextKey
+)
+
+// ContextUserKey is the key under which the Gin and Echo adapters store the authenticated User
+// in their framework contexts (c.Set). It is not used for context.Context values; use
+// ContextWithUser and UserFromContext for those.
 const ContextUserKey = "go-ez-auth-user"
 
+// ContextWithUser returns a copy of ctx carrying user.
+func ContextWithUser(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
 // UserFromContext retrieves the authenticated User from context.
 func UserFromContext(ctx context.Context) (User, bool) {
-	user, ok := ctx.Value(ContextUserKey).(User)
+	user, ok := ctx.Value(userContextKey).(User)
 	return user, ok
 }
 
+// AnonymousID is the ID of the Anonymous user.
+const AnonymousID = "anonymous"
+
+// anonymousUser is the type of Anonymous.
+type anonymousUser struct{}
+
+func (anonymousUser) GetID() string                         { return AnonymousID }
+func (anonymousUser) GetAttributes() map[string]interface{} { return nil }
+
+// Anonymous is the principal that optional authentication stores for requests carrying no
+// credentials, so handlers can tell an anonymous request from one that was never authenticated.
+var Anonymous User = anonymousUser{}
+
+// IsAnonymous reports whether user is the Anonymous principal.
+func IsAnonymous(user User) bool {
+	_, ok := user.(anonymousUser)
+	return ok
+}
+
 // Standard error variables.
 var (
 	ErrUnauthorized       = errors.New("unauthorized")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("user already exists")
+	ErrForbidden          = errors.New("forbidden")
+	ErrInvalidConfig      = errors.New("invalid strategy config")
+	// ErrNoCredentials is returned by strategies when the request carries no credential for them.
+	// It lets the Authenticator tell an absent credential apart from a present but invalid one.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrThrottled is matched by a *ThrottledError, returned while a credential or client is locked out
+	// after too many failed attempts.
+	ErrThrottled = errors.New("too many failed attempts")
 )
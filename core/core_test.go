@@ -2,8 +2,12 @@ package core_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"sync"
 	"testing"
+	"time"
 
 	"go-ez-auth/core"
 )
@@ -35,3 +39,473 @@ func TestStrategyRegistry(t *testing.T) {
 		t.Errorf("expected names ['dummy'], got %v", names)
 	}
 }
+
+type namedStrategy struct {
+	name string
+	user core.User
+	err  error // returned when user is nil; defaults to ErrNoCredentials
+}
+
+func (n namedStrategy) Name() string { return n.name }
+func (n namedStrategy) Setup() error { return nil }
+func (n namedStrategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	if n.user == nil {
+		if n.err != nil {
+			return nil, n.err
+		}
+		return nil, core.ErrNoCredentials
+	}
+	return n.user, nil
+}
+
+type testUser struct{ id string }
+
+func (u testUser) GetID() string                         { return u.id }
+func (u testUser) GetAttributes() map[string]interface{} { return nil }
+
+func TestAuthenticator_Isolated(t *testing.T) {
+	a := core.NewAuthenticator()
+	b := core.NewAuthenticator()
+	a.Register(namedStrategy{name: "x"})
+
+	if _, ok := a.Strategy("x"); !ok {
+		t.Fatal("expected strategy 'x' on a")
+	}
+	if _, ok := b.Strategy("x"); ok {
+		t.Error("expected strategy 'x' to be absent on b")
+	}
+	a.Unregister("x")
+	if names := a.Strategies(); len(names) != 0 {
+		t.Errorf("expected no strategies after Unregister, got %v", names)
+	}
+}
+
+func TestAuthenticator_FirstSuccessWins(t *testing.T) {
+	a := core.NewAuthenticator()
+	a.Register(namedStrategy{name: "fail"})
+	a.Register(namedStrategy{name: "ok", user: testUser{"u1"}})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	user, err := a.Authenticate(req, "missing", "fail", "ok")
+	if err != nil || user.GetID() != "u1" {
+		t.Fatalf("expected u1, got %v %v", user, err)
+	}
+	_, err = a.Authenticate(req, "fail")
+	if !errors.Is(err, core.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+	var chainErr *core.ChainError
+	if !errors.As(err, &chainErr) || len(chainErr.Errors) != 1 || chainErr.Errors[0].Strategy != "fail" {
+		t.Errorf("expected ChainError with one 'fail' entry, got %v", err)
+	}
+}
+
+func TestAuthenticator_ConcurrentRegister(t *testing.T) {
+	a := core.NewAuthenticator()
+	req, _ := http.NewRequest("GET", "/", nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			a.Register(namedStrategy{name: fmt.Sprintf("s%d", i), user: testUser{"u"}})
+			a.Authenticate(req, "s0", "s1")
+			a.Strategies()
+		}(i)
+	}
+	wg.Wait()
+	if n := len(a.Strategies()); n != 50 {
+		t.Errorf("expected 50 strategies, got %d", n)
+	}
+}
+
+func TestAuthError(t *testing.T) {
+	cause := errors.New("boom")
+	err := error(core.NewAuthError("jwt", core.ReasonExpired, cause))
+	if !errors.Is(err, core.ErrUnauthorized) {
+		t.Error("expected AuthError to match ErrUnauthorized")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected AuthError to wrap its cause")
+	}
+	if got := core.ReasonOf(err); got != core.ReasonExpired {
+		t.Errorf("expected reason expired, got %q", got)
+	}
+	if err.Error() != "jwt: expired: boom" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+
+	chain := &core.ChainError{Errors: []*core.AuthError{
+		core.NewAuthError("apikey", core.ReasonMissingCredentials, nil),
+		core.NewAuthError("jwt", core.ReasonExpired, cause),
+	}}
+	if !errors.Is(chain, cause) {
+		t.Error("expected ChainError to expose strategy causes")
+	}
+	if chain.Error() != "unauthorized: apikey: missing_credentials; jwt: expired: boom" {
+		t.Errorf("unexpected message %q", chain.Error())
+	}
+}
+
+func TestStoreReason(t *testing.T) {
+	if r := core.StoreReason(core.ErrUserNotFound); r != core.ReasonInvalidCredentials {
+		t.Errorf("expected invalid_credentials, got %q", r)
+	}
+	if r := core.StoreReason(errors.New("db down")); r != core.ReasonStoreError {
+		t.Errorf("expected store_error, got %q", r)
+	}
+}
+
+func TestAuthenticator_ChainModes(t *testing.T) {
+	a := core.NewAuthenticator()
+	a.Register(namedStrategy{name: "absent"})
+	a.Register(namedStrategy{name: "invalid", err: core.NewAuthError("invalid", core.ReasonExpired, nil)})
+	a.Register(namedStrategy{name: "ok", user: testUser{"u1"}})
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	// An absent credential falls through to the next strategy.
+	if user, err := a.Authenticate(req, "absent", "ok"); err != nil || user.GetID() != "u1" {
+		t.Fatalf("expected u1, got %v %v", user, err)
+	}
+
+	// A present but invalid credential stops the chain by default.
+	_, err := a.Authenticate(req, "absent", "invalid", "ok")
+	var chainErr *core.ChainError
+	if !errors.As(err, &chainErr) {
+		t.Fatalf("expected ChainError, got %v", err)
+	}
+	if len(chainErr.Errors) != 2 {
+		t.Errorf("expected 2 recorded failures, got %v", chainErr.Errors)
+	}
+	if inv := chainErr.Invalid(); inv == nil || inv.Strategy != "invalid" || inv.Reason != core.ReasonExpired {
+		t.Errorf("expected invalid strategy to be reported, got %v", inv)
+	}
+
+	// ContinueOnInvalid keeps trying.
+	if res, err := a.AuthenticateChain(req, core.ContinueOnInvalid, "invalid", "ok"); err != nil || res.User.GetID() != "u1" {
+		t.Fatalf("expected u1 with ContinueOnInvalid, got %v %v", res, err)
+	}
+
+	// A bare ErrUnauthorized from a legacy strategy does not stop the chain.
+	a.Register(namedStrategy{name: "legacy", err: core.ErrUnauthorized})
+	if user, err := a.Authenticate(req, "legacy", "ok"); err != nil || user.GetID() != "u1" {
+		t.Fatalf("expected legacy strategy to fall through to u1, got %v %v", user, err)
+	}
+
+	// Only missing credentials: no invalid failure, and the error matches ErrNoCredentials.
+	_, err = a.Authenticate(req, "absent")
+	if !errors.Is(err, core.ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+	if errors.As(err, &chainErr) && chainErr.Invalid() != nil {
+		t.Errorf("expected no invalid failure, got %v", chainErr.Invalid())
+	}
+}
+
+type lifecycleStrategy struct {
+	name     string
+	setupErr error
+	closed   *bool
+}
+
+func (l lifecycleStrategy) Name() string { return l.name }
+func (l lifecycleStrategy) Setup() error { return l.setupErr }
+func (l lifecycleStrategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	return nil, core.ErrNoCredentials
+}
+func (l lifecycleStrategy) Close() error {
+	*l.closed = true
+	return nil
+}
+
+func TestAuthenticator_Lifecycle(t *testing.T) {
+	a := core.NewAuthenticator()
+	var closed bool
+
+	err := a.Register(lifecycleStrategy{name: "bad", setupErr: core.ErrInvalidConfig, closed: &closed})
+	if !errors.Is(err, core.ErrInvalidConfig) {
+		t.Fatalf("expected setup error, got %v", err)
+	}
+	if _, ok := a.Strategy("bad"); ok {
+		t.Error("expected strategy with failing Setup not to be registered")
+	}
+
+	var replaced bool
+	if err := a.Register(lifecycleStrategy{name: "good", closed: &replaced}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := a.Register(lifecycleStrategy{name: "good", closed: &closed}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if !replaced || closed {
+		t.Errorf("expected only the replaced strategy to be closed, got replaced=%v closed=%v", replaced, closed)
+	}
+
+	// Registering the same strategy again does not close it.
+	var again bool
+	same := &lifecycleStrategy{name: "same", closed: &again}
+	a.Register(same)
+	a.Register(same)
+	if again {
+		t.Error("expected re-registering a strategy not to close it")
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !closed {
+		t.Error("expected Close to close the strategy")
+	}
+	if names := a.Strategies(); len(names) != 0 {
+		t.Errorf("expected no strategies after Close, got %v", names)
+	}
+}
+
+func TestContextWithUser(t *testing.T) {
+	ctx := core.ContextWithUser(context.Background(), testUser{"u1"})
+	user, ok := core.UserFromContext(ctx)
+	if !ok || user.GetID() != "u1" {
+		t.Fatalf("expected u1, got %v %v", user, ok)
+	}
+	// A plain string key with the same value must not collide.
+	ctx = context.WithValue(context.Background(), core.ContextUserKey, testUser{"u2"})
+	if _, ok := core.UserFromContext(ctx); ok {
+		t.Error("expected string-keyed value to be ignored")
+	}
+}
+
+func TestAuthenticator_Events(t *testing.T) {
+	a := core.NewAuthenticator()
+	a.Register(namedStrategy{name: "ok", user: testUser{"u1"}})
+	a.Register(namedStrategy{name: "absent"})
+	a.Register(namedStrategy{name: "invalid", err: core.NewAuthError("invalid", core.ReasonBadSignature, nil)})
+
+	var events []core.Event
+	unsubscribe := a.Subscribe(func(ctx context.Context, e core.Event) { events = append(events, e) })
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("User-Agent", "test-agent")
+	// Neither a successful chain nor one without credentials is a login event.
+	a.Authenticate(req, "ok")
+	a.Authenticate(req, "absent")
+	a.Authenticate(req, "absent", "invalid")
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %+v", events)
+	}
+	if e := events[0]; e.Type != core.EventLoginFailure || e.Strategy != "invalid" || e.Reason != core.ReasonBadSignature || e.RemoteIP != "10.0.0.1" || e.UserAgent != "test-agent" {
+		t.Errorf("unexpected failure event %+v", e)
+	}
+
+	unsubscribe()
+	a.Authenticate(req, "invalid")
+	if len(events) != 1 {
+		t.Errorf("expected no events after unsubscribe, got %d", len(events))
+	}
+}
+
+func TestAuthenticateChain_ResultDefaults(t *testing.T) {
+	a := core.NewAuthenticator()
+	a.Register(namedStrategy{name: "ok", user: testUser{"u1"}})
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	before := time.Now()
+	res, err := a.AuthenticateChain(req, core.StopOnInvalid, "ok")
+	if err != nil {
+		t.Fatalf("AuthenticateChain: %v", err)
+	}
+	if res.User.GetID() != "u1" || res.Strategy != "ok" || len(res.Methods) != 1 || res.Methods[0] != "ok" {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if res.AuthTime.Before(before) {
+		t.Errorf("expected AuthTime to default to now, got %v", res.AuthTime)
+	}
+
+	ctx := core.ContextWithResult(context.Background(), res)
+	if got, ok := core.ResultFromContext(ctx); !ok || got != res {
+		t.Error("expected result in context")
+	}
+	if user, ok := core.UserFromContext(ctx); !ok || user.GetID() != "u1" {
+		t.Error("expected ContextWithResult to also store the user")
+	}
+}
+
+func TestAllOf(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	cert := namedStrategy{name: "mtls", user: testUser{"u1"}}
+	token := namedStrategy{name: "jwt", user: testUser{"u1"}}
+
+	all := core.AllOf(cert, token)
+	if all.Name() != "all_of(mtls,jwt)" {
+		t.Errorf("unexpected name %q", all.Name())
+	}
+	res, err := all.AuthenticateResult(context.Background(), req)
+	if err != nil || res.User.GetID() != "u1" {
+		t.Fatalf("expected u1, got %v %v", res, err)
+	}
+	if len(res.Methods) != 2 || res.Methods[0] != "mtls" || res.Methods[1] != "jwt" {
+		t.Errorf("expected methods [mtls jwt], got %v", res.Methods)
+	}
+
+	// Disagreeing users are rejected as invalid.
+	_, err = core.AllOf(cert, namedStrategy{name: "jwt", user: testUser{"u2"}}).Authenticate(context.Background(), req)
+	if core.ReasonOf(err) != core.ReasonInvalidCredentials {
+		t.Errorf("expected invalid_credentials for disagreeing users, got %v", err)
+	}
+
+	// A missing credential keeps the child's reason so chains can move on.
+	_, err = core.AllOf(cert, namedStrategy{name: "jwt"}).Authenticate(context.Background(), req)
+	if !errors.Is(err, core.ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+
+	if err := core.AllOf().Setup(); !errors.Is(err, core.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for empty AllOf, got %v", err)
+	}
+}
+
+func TestAnyOf(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	invalid := namedStrategy{name: "invalid", err: core.NewAuthError("invalid", core.ReasonExpired, nil)}
+	ok := namedStrategy{name: "ok", user: testUser{"u1"}}
+
+	res, err := core.AnyOf(namedStrategy{name: "absent"}, ok).AuthenticateResult(context.Background(), req)
+	if err != nil || res.Strategy != "ok" {
+		t.Fatalf("expected success from ok, got %v %v", res, err)
+	}
+
+	_, err = core.AnyOf(invalid, ok).Authenticate(context.Background(), req)
+	if core.ReasonOf(err) != core.ReasonExpired {
+		t.Errorf("expected expired to stop the chain, got %v", err)
+	}
+	var chainErr *core.ChainError
+	if !errors.As(err, &chainErr) || len(chainErr.Errors) != 1 {
+		t.Errorf("expected wrapped ChainError with one failure, got %v", err)
+	}
+
+	if _, err := core.AnyOf(invalid, ok).WithChainMode(core.ContinueOnInvalid).Authenticate(context.Background(), req); err != nil {
+		t.Errorf("expected ContinueOnInvalid to reach ok, got %v", err)
+	}
+}
+
+func TestWhen(t *testing.T) {
+	a := core.NewAuthenticator()
+	internal := core.AllOf(namedStrategy{name: "mtls", user: testUser{"svc"}}, namedStrategy{name: "jwt", user: testUser{"svc"}})
+	if err := a.Register(core.Named("internal", core.When(core.PathPrefix("/internal/"), internal))); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	a.Register(namedStrategy{name: "public", user: testUser{"anon"}})
+
+	req, _ := http.NewRequest("GET", "/internal/stats", nil)
+	res, err := a.AuthenticateChain(req, core.StopOnInvalid, "internal", "public")
+	if err != nil || res.User.GetID() != "svc" || res.Strategy != "all_of(mtls,jwt)" {
+		t.Fatalf("expected svc via all_of(mtls,jwt), got %+v %v", res, err)
+	}
+
+	req, _ = http.NewRequest("GET", "/docs", nil)
+	res, err = a.AuthenticateChain(req, core.StopOnInvalid, "internal", "public")
+	if err != nil || res.User.GetID() != "anon" {
+		t.Fatalf("expected non-matching request to fall through, got %+v %v", res, err)
+	}
+}
+
+type impersonatingStrategy struct{}
+
+func (impersonatingStrategy) Name() string { return "imp" }
+func (impersonatingStrategy) Setup() error { return nil }
+func (impersonatingStrategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	return testUser{"customer"}, nil
+}
+func (impersonatingStrategy) AuthenticateResult(ctx context.Context, r *http.Request) (*core.AuthResult, error) {
+	return &core.AuthResult{User: testUser{"customer"}, Actor: testUser{"admin"}}, nil
+}
+
+func TestImpersonation_ContextAndEvents(t *testing.T) {
+	a := core.NewAuthenticator()
+	a.Register(impersonatingStrategy{})
+	revoked := core.NewAuthError("revoked", core.ReasonInvalidCredentials, core.ErrForbidden)
+	revoked.UserID, revoked.ActorID = "customer", "admin"
+	a.Register(namedStrategy{name: "revoked", err: revoked})
+	var events []core.Event
+	a.Subscribe(func(ctx context.Context, e core.Event) { events = append(events, e) })
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	res, err := a.AuthenticateChain(req, core.StopOnInvalid, "imp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.AuthenticateChain(req, core.StopOnInvalid, "revoked")
+	if len(events) != 2 {
+		t.Fatalf("expected every impersonated request to be audited, got %+v", events)
+	}
+	if e := events[0]; e.Type != core.EventLoginSuccess || e.UserID != "customer" || e.ActorID != "admin" {
+		t.Errorf("expected impersonation in success event, got %+v", e)
+	}
+	if e := events[1]; e.Type != core.EventLoginFailure || e.UserID != "customer" || e.ActorID != "admin" {
+		t.Errorf("expected impersonation in failure event, got %+v", e)
+	}
+
+	ctx := core.ContextWithResult(context.Background(), res)
+	user, _ := core.UserFromContext(ctx)
+	actor, _ := core.ActorFromContext(ctx)
+	if user.GetID() != "customer" || actor.GetID() != "admin" || !core.IsImpersonated(ctx) {
+		t.Errorf("expected customer acted on by admin, got %v %v", user, actor)
+	}
+
+	ctx = core.ContextWithUser(context.Background(), testUser{"u1"})
+	if actor, _ := core.ActorFromContext(ctx); actor.GetID() != "u1" || core.IsImpersonated(ctx) {
+		t.Errorf("expected the user to be its own actor, got %v", actor)
+	}
+}
+
+type challengingStrategy struct {
+	namedStrategy
+	scheme string
+}
+
+func (c challengingStrategy) Challenge(r *http.Request, err *core.AuthError) string {
+	if err != nil {
+		return core.Challenge(c.scheme, "error", string(err.Reason))
+	}
+	return core.Challenge(c.scheme)
+}
+
+func TestChallenges(t *testing.T) {
+	if got := core.Challenge("Basic", "realm", `say "hi"`, "empty", ""); got != `Basic realm="say \"hi\""` {
+		t.Errorf("unexpected challenge %s", got)
+	}
+
+	a := core.NewAuthenticator()
+	a.Register(challengingStrategy{namedStrategy{name: "bearer", err: core.NewAuthError("bearer", core.ReasonExpired, nil)}, "Bearer"})
+	a.Register(challengingStrategy{namedStrategy{name: "basic"}, "Basic"})
+	a.Register(namedStrategy{name: "plain"})
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	_, err := a.Authenticate(req, "plain", "bearer", "basic")
+	var chainErr *core.ChainError
+	if !errors.As(err, &chainErr) {
+		t.Fatalf("expected ChainError, got %v", err)
+	}
+	// basic was never tried because bearer stopped the chain, but is still offered.
+	want := []string{`Bearer error="expired"`, "Basic"}
+	if fmt.Sprint(chainErr.Challenges) != fmt.Sprint(want) {
+		t.Errorf("expected challenges %q, got %q", want, chainErr.Challenges)
+	}
+
+	// Composites and Named offer their children's challenges, each with its own failure.
+	b := core.NewAuthenticator()
+	b.Register(core.AnyOf(
+		challengingStrategy{namedStrategy{name: "bearer", err: core.NewAuthError("bearer", core.ReasonExpired, nil)}, "Bearer"},
+		challengingStrategy{namedStrategy{name: "basic"}, "Basic"},
+	))
+	b.Register(core.Named("admin", core.When(core.PathPrefix("/admin"), challengingStrategy{namedStrategy{name: "digest"}, "Digest"})))
+	b.Register(core.AllOf(challengingStrategy{namedStrategy{name: "mtls"}, "Mutual"}, namedStrategy{name: "plain"}))
+	_, err = b.AuthenticateChain(req, core.ContinueOnInvalid, "any_of(bearer,basic)", "admin", "all_of(mtls,plain)")
+	if !errors.As(err, &chainErr) {
+		t.Fatalf("expected ChainError, got %v", err)
+	}
+	want = []string{`Bearer error="expired", Basic`, "Digest", `Mutual error="missing_credentials"`}
+	if fmt.Sprint(chainErr.Challenges) != fmt.Sprint(want) {
+		t.Errorf("expected composite challenges %q, got %q", want, chainErr.Challenges)
+	}
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Reason is a machine-readable code describing why a strategy rejected a request.
+type Reason string
+
+// Reason codes reported by the built-in strategies.
+const (
+	ReasonMissingCredentials Reason = "missing_credentials"
+	ReasonInvalidCredentials Reason = "invalid_credentials"
+	ReasonInvalidToken       Reason = "invalid_token"
+	ReasonExpired            Reason = "expired"
+	ReasonBadSignature       Reason = "bad_signature"
+	ReasonUnknownKey         Reason = "unknown_key"
+	ReasonStoreError         Reason = "store_error"
+	ReasonUpstreamError      Reason = "upstream_error"
+	ReasonUnknownTenant      Reason = "unknown_tenant"
+	ReasonThrottled          Reason = "throttled"
+)
+
+// AuthError records a failure of a single strategy: which strategy failed, why, and the underlying cause.
+// AuthError matches ErrUnauthorized with errors.Is, so callers that only care about the outcome keep working,
+// and an AuthError with ReasonMissingCredentials also matches ErrNoCredentials.
+type AuthError struct {
+	Strategy string
+	Reason   Reason
+	Err      error
+	// UserID and ActorID identify the target and the real user of a rejected impersonation,
+	// so that the failure is audited as one.
+	UserID, ActorID string
+}
+
+// NewAuthError creates an AuthError for the named strategy.
+func NewAuthError(strategy string, reason Reason, err error) *AuthError {
+	return &AuthError{Strategy: strategy, Reason: reason, Err: err}
+}
+
+// NoCredentialsError creates an AuthError reporting that the named strategy found no credential in the request.
+func NoCredentialsError(strategy string) *AuthError {
+	return NewAuthError(strategy, ReasonMissingCredentials, ErrNoCredentials)
+}
+
+// CredentialsPresent reports whether the strategy found a credential, i.e. whether the failure was
+// caused by an invalid credential or backend error rather than an absent one.
+func (e *AuthError) CredentialsPresent() bool {
+	return e.Reason != ReasonMissingCredentials
+}
+
+// Error returns a detailed message intended for logs, not for clients.
+func (e *AuthError) Error() string {
+	msg := e.Strategy + ": " + string(e.Reason)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying cause.
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrUnauthorized, or ErrNoCredentials for a missing credential.
+func (e *AuthError) Is(target error) bool {
+	return target == ErrUnauthorized || (target == ErrNoCredentials && !e.CredentialsPresent())
+}
+
+// ChainError aggregates the failures of every strategy tried for a request.
+// Like AuthError, it matches ErrUnauthorized with errors.Is.
+type ChainError struct {
+	Errors []*AuthError
+	// Challenges holds the WWW-Authenticate challenges of the strategies in the chain that
+	// implement Challenger.
+	Challenges []string
+}
+
+// Error returns a detailed message listing each strategy failure.
+func (e *ChainError) Error() string {
+	if len(e.Errors) == 0 {
+		return ErrUnauthorized.Error()
+	}
+	parts := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		parts[i] = err.Error()
+	}
+	return ErrUnauthorized.Error() + ": " + strings.Join(parts, "; ")
+}
+
+// Invalid returns the first failure caused by a credential that was present, or nil if
+// every strategy tried reported missing credentials.
+func (e *ChainError) Invalid() *AuthError {
+	for _, err := range e.Errors {
+		if err.CredentialsPresent() {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unwrap exposes the individual strategy failures to errors.Is and errors.As.
+func (e *ChainError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Is reports whether target is ErrUnauthorized.
+func (e *ChainError) Is(target error) bool {
+	return target == ErrUnauthorized
+}
+
+// ReasonOf returns the Reason of the first AuthError found in err's chain, or "" if there is none.
+func ReasonOf(err error) Reason {
+	var ae *AuthError
+	if errors.As(err, &ae) {
+		return ae.Reason
+	}
+	return ""
+}
+
+// StoreReason classifies an error returned by a UserStore: lookups that found no matching
+// user map to ReasonInvalidCredentials, anything else to ReasonStoreError.
+func StoreReason(err error) Reason {
+	if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrInvalidCredentials) {
+		return ReasonInvalidCredentials
+	}
+	return ReasonStoreError
+}
+
+// ThrottledError reports that an attempt was refused without checking the credential because
+// its username, key, or client is temporarily locked out. It matches ErrThrottled with errors.Is.
+type ThrottledError struct {
+	RetryAfter time.Duration // how long until the lockout ends
+}
+
+// Error returns the lockout message with the remaining duration.
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", ErrThrottled, e.RetryAfter.Round(time.Second))
+}
+
+// Is reports whether target is ErrThrottled.
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrThrottled
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// EventType identifies an authentication event.
+type EventType string
+
+// Event types emitted by the Authenticator and the built-in strategies and stores.
+const (
+	EventLoginSuccess EventType = "login_success"
+	EventLoginFailure EventType = "login_failure"
+	EventLogout       EventType = "logout"
+	EventTokenIssued  EventType = "token_issued"
+	EventKeyRevoked   EventType = "key_revoked"
+
+	EventImpersonationStart EventType = "impersonation_start"
+	EventImpersonationEnd   EventType = "impersonation_end"
+
+	EventStoreReloaded     EventType = "store_reloaded"
+	EventStoreReloadFailed EventType = "store_reload_failed" // Err holds the cause; the old data stays in use
+)
+
+// Event describes something that happened during authentication.
+type Event struct {
+	Type      EventType
+	Time      time.Time
+	Strategy  string
+	UserID    string
+	ActorID   string // set when UserID is being impersonated by ActorID
+	RemoteIP  string
+	UserAgent string
+	Reason    Reason // why a login failed
+	Err       error  // detailed failure cause, for logs only
+}
+
+// NewEvent creates an event of type t stamped with the current time and, if r is non-nil,
+// the client's remote IP and user agent.
+func NewEvent(t EventType, r *http.Request) Event {
+	e := Event{Type: t, Time: time.Now()}
+	if r != nil {
+		e.RemoteIP = RemoteIP(r)
+		e.UserAgent = r.UserAgent()
+	}
+	return e
+}
+
+// RemoteIP returns the host part of r.RemoteAddr. Forwarding headers are not trusted.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// EventHandler receives authentication events. Handlers run synchronously on the
+// request path and should return quickly.
+type EventHandler func(ctx context.Context, e Event)
+
+// EventEmitter publishes events to subscribers. *Authenticator implements it, so strategies
+// and stores can be pointed at the Authenticator they are registered with.
+type EventEmitter interface {
+	Emit(ctx context.Context, e Event)
+}
+
+// subscription pairs a handler with an identity so it can be removed.
+type subscription struct {
+	id      uint64
+	handler EventHandler
+}
+
+// Subscribe registers h to receive every event emitted by the Authenticator and
+// returns a function that removes it.
+func (a *Authenticator) Subscribe(h EventHandler) (unsubscribe func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.nextSubID++
+	id := a.nextSubID
+	a.subscribers = append(a.subscribers, subscription{id: id, handler: h})
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		for i, s := range a.subscribers {
+			if s.id == id {
+				a.subscribers = append(a.subscribers[:i:i], a.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Emit delivers e to every subscriber, filling in Time if it is zero.
+func (a *Authenticator) Emit(ctx context.Context, e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	a.mu.RLock()
+	subs := a.subscribers
+	a.mu.RUnlock()
+	for _, s := range subs {
+		s.handler(ctx, e)
+	}
+}
@@ -0,0 +1,50 @@
+package core
+
+import "time"
+
+// Outcome classifies the result of a single strategy attempt for metrics.
+type Outcome string
+
+// Outcomes reported to Metrics.
+const (
+	OutcomeSuccess       Outcome = "success"        // the strategy authenticated the request
+	OutcomeNoCredentials Outcome = "no_credentials" // the request carried no credential for the strategy
+	OutcomeFailure       Outcome = "failure"        // the credential was present but rejected
+	OutcomeError         Outcome = "error"          // a backend (store, identity provider) failed
+)
+
+// Metrics records authentication outcomes and latencies. Implementations must be safe for concurrent use.
+type Metrics interface {
+	ObserveAuthentication(strategy string, outcome Outcome, duration time.Duration)
+}
+
+// OutcomeOf classifies a strategy result; err is nil on success.
+func OutcomeOf(err error) Outcome {
+	if err == nil {
+		return OutcomeSuccess
+	}
+	switch ReasonOf(err) {
+	case ReasonMissingCredentials:
+		return OutcomeNoCredentials
+	case ReasonStoreError, ReasonUpstreamError:
+		return OutcomeError
+	}
+	return OutcomeFailure
+}
+
+// SetMetrics sets the recorder that observes every strategy attempt; nil disables metrics.
+func (a *Authenticator) SetMetrics(m Metrics) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.metrics = m
+}
+
+// observe reports a strategy attempt to the configured Metrics, if any.
+func (a *Authenticator) observe(strategy string, start time.Time, err error) {
+	a.mu.RLock()
+	m := a.metrics
+	a.mu.RUnlock()
+	if m != nil {
+		m.ObserveAuthentication(strategy, OutcomeOf(err), time.Since(start))
+	}
+}
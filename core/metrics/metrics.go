@@ -0,0 +1,168 @@
+// Package metrics provides an in-process core.Metrics implementation with counters and
+// latency histograms per strategy and outcome, plus a dependency-free handler that
+// serves them in the Prometheus text exposition format:
+//
+//	reg := metrics.New(metrics.Config{})
+//	auth.SetMetrics(reg)
+//	http.Handle("/metrics", reg.Handler())
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-ez-auth/core"
+)
+
+// DefaultBuckets are latency histogram upper bounds in seconds, suited to both local
+// checks (JWT, API keys) and remote calls (OAuth2 userinfo).
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Config holds settings for a Registry.
+type Config struct {
+	Namespace string    // metric name prefix; defaults to "goezauth"
+	Buckets   []float64 // histogram upper bounds in seconds; defaults to DefaultBuckets
+}
+
+// series identifies one strategy/outcome combination.
+type series struct {
+	strategy string
+	outcome  core.Outcome
+}
+
+// histogram accumulates observations for one series.
+type histogram struct {
+	counts []uint64 // per bucket, non-cumulative
+	count  uint64
+	sum    float64
+}
+
+// Registry records authentication metrics. It implements core.Metrics and is safe for concurrent use.
+type Registry struct {
+	config Config
+
+	mu         sync.Mutex
+	histograms map[series]*histogram
+}
+
+// New creates a Registry.
+func New(config Config) *Registry {
+	if config.Namespace == "" {
+		config.Namespace = "goezauth"
+	}
+	if len(config.Buckets) == 0 {
+		config.Buckets = DefaultBuckets
+	}
+	buckets := append([]float64(nil), config.Buckets...)
+	sort.Float64s(buckets)
+	config.Buckets = buckets
+	return &Registry{config: config, histograms: make(map[series]*histogram)}
+}
+
+// ObserveAuthentication records one strategy attempt.
+func (r *Registry) ObserveAuthentication(strategy string, outcome core.Outcome, duration time.Duration) {
+	seconds := duration.Seconds()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := series{strategy: strategy, outcome: outcome}
+	h, ok := r.histograms[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(r.config.Buckets))}
+		r.histograms[key] = h
+	}
+	for i, bound := range r.config.Buckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// Count returns the number of attempts recorded for strategy and outcome.
+func (r *Registry) Count(strategy string, outcome core.Outcome) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h, ok := r.histograms[series{strategy: strategy, outcome: outcome}]; ok {
+		return h.count
+	}
+	return 0
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	keys := make([]series, 0, len(r.histograms))
+	snapshot := make(map[series]histogram, len(r.histograms))
+	for k, h := range r.histograms {
+		keys = append(keys, k)
+		snapshot[k] = histogram{counts: append([]uint64(nil), h.counts...), count: h.count, sum: h.sum}
+	}
+	r.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].strategy != keys[j].strategy {
+			return keys[i].strategy < keys[j].strategy
+		}
+		return keys[i].outcome < keys[j].outcome
+	})
+
+	var b strings.Builder
+	total := r.config.Namespace + "_authentications_total"
+	fmt.Fprintf(&b, "# HELP %s Authentication attempts by strategy and outcome.\n", total)
+	fmt.Fprintf(&b, "# TYPE %s counter\n", total)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s{%s} %d\n", total, labels(k), snapshot[k].count)
+	}
+
+	duration := r.config.Namespace + "_authentication_duration_seconds"
+	fmt.Fprintf(&b, "# HELP %s Time spent in strategy Authenticate calls.\n", duration)
+	fmt.Fprintf(&b, "# TYPE %s histogram\n", duration)
+	for _, k := range keys {
+		h := snapshot[k]
+		var cumulative uint64
+		for i, bound := range r.config.Buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"%s\"} %d\n", duration, labels(k), formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", duration, labels(k), h.count)
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", duration, labels(k), formatFloat(h.sum))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", duration, labels(k), h.count)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler returns an http.Handler serving the metrics for Prometheus to scrape.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+// labels renders the label set for a series.
+func labels(k series) string {
+	return fmt.Sprintf("strategy=\"%s\",outcome=\"%s\"", escapeLabel(k.strategy), escapeLabel(string(k.outcome)))
+}
+
+// labelEscaper escapes label values as required by the exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,93 @@
+package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-ez-auth/core"
+	"go-ez-auth/core/metrics"
+)
+
+type stubStrategy struct {
+	name string
+	err  error
+}
+
+func (s stubStrategy) Name() string { return s.name }
+func (s stubStrategy) Setup() error { return nil }
+func (s stubStrategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return stubUser{}, nil
+}
+
+type stubUser struct{}
+
+func (stubUser) GetID() string                         { return "u1" }
+func (stubUser) GetAttributes() map[string]interface{} { return nil }
+
+func TestRegistry_RecordsAuthenticatorOutcomes(t *testing.T) {
+	reg := metrics.New(metrics.Config{})
+	a := core.NewAuthenticator()
+	a.SetMetrics(reg)
+	a.Register(stubStrategy{name: "none", err: core.ErrNoCredentials})
+	a.Register(stubStrategy{name: "store", err: core.NewAuthError("store", core.ReasonStoreError, nil)})
+	a.Register(stubStrategy{name: "ok"})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	a.Authenticate(req, "none", "ok")
+	a.Authenticate(req, "none", "ok")
+	a.Authenticate(req, "store")
+
+	checks := []struct {
+		strategy string
+		outcome  core.Outcome
+		want     uint64
+	}{
+		{"none", core.OutcomeNoCredentials, 2},
+		{"ok", core.OutcomeSuccess, 2},
+		{"store", core.OutcomeError, 1},
+		{"ok", core.OutcomeFailure, 0},
+	}
+	for _, c := range checks {
+		if got := reg.Count(c.strategy, c.outcome); got != c.want {
+			t.Errorf("%s/%s: expected %d, got %d", c.strategy, c.outcome, c.want, got)
+		}
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	reg := metrics.New(metrics.Config{Namespace: "auth", Buckets: []float64{0.1, 1}})
+	reg.ObserveAuthentication("jwt", core.OutcomeSuccess, 50*time.Millisecond)
+	reg.ObserveAuthentication("jwt", core.OutcomeSuccess, 500*time.Millisecond)
+	reg.ObserveAuthentication(`we"ird`, core.OutcomeFailure, 2*time.Second)
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	for _, want := range []string{
+		"# TYPE auth_authentications_total counter\n",
+		`auth_authentications_total{strategy="jwt",outcome="success"} 2` + "\n",
+		`auth_authentications_total{strategy="we\"ird",outcome="failure"} 1` + "\n",
+		"# TYPE auth_authentication_duration_seconds histogram\n",
+		`auth_authentication_duration_seconds_bucket{strategy="jwt",outcome="success",le="0.1"} 1` + "\n",
+		`auth_authentication_duration_seconds_bucket{strategy="jwt",outcome="success",le="1"} 2` + "\n",
+		`auth_authentication_duration_seconds_bucket{strategy="jwt",outcome="success",le="+Inf"} 2` + "\n",
+		`auth_authentication_duration_seconds_sum{strategy="jwt",outcome="success"} 0.55` + "\n",
+		`auth_authentication_duration_seconds_bucket{strategy="we\"ird",outcome="failure",le="1"} 0` + "\n",
+		`auth_authentication_duration_seconds_count{strategy="we\"ird",outcome="failure"} 1` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected output to contain %q\n%s", want, body)
+		}
+	}
+}
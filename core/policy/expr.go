@@ -0,0 +1,575 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression is a compiled policy condition.
+//
+// The language supports:
+//   - literals: "strings" or 'strings', numbers, true, false, null, and lists like ["GET", "HEAD"]
+//   - attribute paths: user.department, request.method, resource.owner_dept
+//   - comparisons: ==, !=, <, <=, >, >=
+//   - membership: x in list, x not in list (a string on the right tests for a substring)
+//   - boolean logic: &&, ||, ! and parentheses
+//
+// A comparison or membership test involving a missing attribute is false, whichever operator is
+// used, so that absent data never satisfies a rule; "== null" and "!= null" test for presence.
+type Expression struct {
+	src  string
+	root node
+}
+
+// Compile parses src into an Expression.
+func Compile(src string) (*Expression, error) {
+	p := &parser{lex: lexer{src: src}}
+	p.next()
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return &Expression{src: src, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression against env and requires a boolean result.
+func (e *Expression) Eval(env map[string]interface{}) (bool, error) {
+	v, err := e.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("policy: expression %q evaluated to %T, not bool", e.src, v)
+	}
+	return b, nil
+}
+
+// --- lexer ---
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+// twoCharOps lists operators that are two characters long.
+var twoCharOps = []string{"==", "!=", "<=", ">=", "&&", "||"}
+
+func (l *lexer) scan() (token, error) {
+	for l.pos < len(l.src) && unicode.IsSpace(rune(l.src[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || unicode.IsLetter(rune(l.src[l.pos])) || unicode.IsDigit(rune(l.src[l.pos]))) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}, nil
+	case unicode.IsDigit(rune(c)) || (c == '-' && l.pos+1 < len(l.src) && unicode.IsDigit(rune(l.src[l.pos+1]))):
+		l.pos++
+		for l.pos < len(l.src) && (unicode.IsDigit(rune(l.src[l.pos])) || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}, nil
+	case c == '"' || c == '\'':
+		l.pos++
+		var sb strings.Builder
+		for l.pos < len(l.src) && l.src[l.pos] != c {
+			if l.src[l.pos] == '\\' && l.pos+1 < len(l.src) {
+				l.pos++
+			}
+			sb.WriteByte(l.src[l.pos])
+			l.pos++
+		}
+		if l.pos >= len(l.src) {
+			return token{}, fmt.Errorf("policy: unterminated string at offset %d", start)
+		}
+		l.pos++
+		return token{kind: tokString, text: sb.String(), pos: start}, nil
+	}
+	for _, op := range twoCharOps {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += 2
+			return token{kind: tokOp, text: op, pos: start}, nil
+		}
+	}
+	if strings.ContainsRune("<>!()[],.", rune(c)) {
+		l.pos++
+		return token{kind: tokOp, text: string(c), pos: start}, nil
+	}
+	return token{}, fmt.Errorf("policy: unexpected character %q at offset %d", c, start)
+}
+
+// --- parser ---
+
+type parser struct {
+	lex lexer
+	tok token
+	err error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lex.scan()
+	if p.err != nil {
+		p.tok = token{kind: tokEOF, pos: p.lex.pos}
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	return fmt.Errorf("policy: %s at offset %d in %q", fmt.Sprintf(format, args...), p.tok.pos, p.lex.src)
+}
+
+func (p *parser) isOp(text string) bool {
+	return p.tok.kind == tokOp && p.tok.text == text
+}
+
+func (p *parser) isKeyword(text string) bool {
+	return p.tok.kind == tokIdent && p.tok.text == text
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+// comparisonOps lists the binary comparison operators.
+var comparisonOps = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.tok.kind == tokOp && comparisonOps[p.tok.text]:
+		op := p.tok.text
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{op: op, left: left, right: right}, nil
+	case p.isKeyword("in"):
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return inNode{left: left, right: right}, nil
+	case p.isKeyword("not"):
+		p.next()
+		if !p.isKeyword("in") {
+			return nil, p.errorf("expected \"in\" after \"not\"")
+		}
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return inNode{left: left, right: right, not: true}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokString:
+		p.next()
+		return literalNode{value: tok.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", tok)
+		}
+		p.next()
+		return literalNode{value: f}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			p.next()
+			return literalNode{value: tok.text == "true"}, nil
+		case "null":
+			p.next()
+			return literalNode{value: nil}, nil
+		case "in", "not":
+			return nil, p.errorf("unexpected %s", tok)
+		}
+		path := []string{tok.text}
+		p.next()
+		for p.isOp(".") {
+			p.next()
+			if p.tok.kind != tokIdent {
+				return nil, p.errorf("expected attribute name after \".\"")
+			}
+			path = append(path, p.tok.text)
+			p.next()
+		}
+		return pathNode{path: path}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			p.next()
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOp(")") {
+				return nil, p.errorf("expected \")\"")
+			}
+			p.next()
+			return inner, nil
+		case "[":
+			p.next()
+			var items []node
+			for !p.isOp("]") {
+				item, err := p.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+				if p.isOp(",") {
+					p.next()
+				} else if !p.isOp("]") {
+					return nil, p.errorf("expected \",\" or \"]\"")
+				}
+			}
+			p.next()
+			return listNode{items: items}, nil
+		}
+	}
+	return nil, p.errorf("unexpected %s", tok)
+}
+
+// --- evaluation ---
+
+type node interface {
+	eval(env map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct{ value interface{} }
+
+func (n literalNode) eval(map[string]interface{}) (interface{}, error) { return n.value, nil }
+
+type listNode struct{ items []node }
+
+func (n listNode) eval(env map[string]interface{}) (interface{}, error) {
+	out := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// missing is the value of an attribute path that does not resolve. It is unequal to everything,
+// itself included, and only compares equal to null.
+type missing struct{}
+
+// pathNode resolves a dotted attribute path; missing attributes evaluate to missing{}.
+type pathNode struct{ path []string }
+
+func (n pathNode) eval(env map[string]interface{}) (interface{}, error) {
+	var cur interface{} = env
+	for _, key := range n.path {
+		var ok bool
+		switch m := cur.(type) {
+		case map[string]interface{}:
+			cur, ok = m[key]
+		case map[string]string:
+			cur, ok = m[key]
+		}
+		if !ok {
+			return missing{}, nil
+		}
+	}
+	return normalize(cur), nil
+}
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(env map[string]interface{}) (interface{}, error) {
+	b, err := evalBool(n.operand, env)
+	if err != nil {
+		return nil, err
+	}
+	return !b, nil
+}
+
+type logicNode struct {
+	and         bool
+	left, right node
+}
+
+func (n logicNode) eval(env map[string]interface{}) (interface{}, error) {
+	l, err := evalBool(n.left, env)
+	if err != nil {
+		return nil, err
+	}
+	if l != n.and {
+		// short-circuit: false && x, true || x
+		return l, nil
+	}
+	return evalBool(n.right, env)
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n compareNode) eval(env map[string]interface{}) (interface{}, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==", "!=":
+		l, r, ok := presence(l, r)
+		if !ok {
+			return false, nil
+		}
+		return equal(l, r) == (n.op == "=="), nil
+	}
+	// Ordering comparisons are false unless both sides are numbers or both are strings.
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return false, nil
+		}
+		return order(n.op, compareFloat(lv, rv)), nil
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return false, nil
+		}
+		return order(n.op, strings.Compare(lv, rv)), nil
+	}
+	return false, nil
+}
+
+type inNode struct {
+	left, right node
+	not         bool
+}
+
+func (n inNode) eval(env map[string]interface{}) (interface{}, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if l == (missing{}) || r == (missing{}) {
+		return false, nil
+	}
+	var found bool
+	switch rv := r.(type) {
+	case []interface{}:
+		for _, item := range rv {
+			if equal(l, item) {
+				found = true
+				break
+			}
+		}
+	case string:
+		ls, ok := l.(string)
+		found = ok && strings.Contains(rv, ls)
+	case nil:
+	default:
+		return nil, fmt.Errorf("policy: \"in\" requires a list or string, got %T", r)
+	}
+	return found != n.not, nil
+}
+
+func evalBool(n node, env map[string]interface{}) (bool, error) {
+	v, err := n.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("policy: expected bool operand, got %T", v)
+	}
+	return b, nil
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func order(op string, cmp int) bool {
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	if a == (missing{}) || b == (missing{}) {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// presence prepares the operands of == and != when either may be missing: a missing attribute
+// compared with null is treated as null, and any other comparison with it reports !ok.
+func presence(l, r interface{}) (interface{}, interface{}, bool) {
+	switch {
+	case l == (missing{}) && r == nil, r == (missing{}) && l == nil:
+		return nil, nil, true
+	case l == (missing{}), r == (missing{}):
+		return nil, nil, false
+	}
+	return l, r, true
+}
+
+// normalize converts attribute values to the types the evaluator works with:
+// numbers become float64 and slices become []interface{}.
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil, bool, string, float64, map[string]interface{}:
+		return x
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, item := range x {
+			out[i] = normalize(item)
+		}
+		return out
+	case int:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case uint:
+		return float64(x)
+	case uint32:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case fmt.Stringer:
+		return x.String()
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice {
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = normalize(rv.Index(i).Interface())
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,253 @@
+// Package policy implements an attribute-based policy engine for go-ez-auth.
+// Policies are ordered lists of rules, loaded from JSON or YAML, whose conditions are
+// small expressions evaluated against the authenticated user's attributes, request
+// metadata, and caller-supplied resource attributes:
+//
+//	user.department == resource.owner_dept && request.method in ["GET", "HEAD"]
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-ez-auth/core"
+	"gopkg.in/yaml.v3"
+)
+
+// Effect is the outcome a rule produces when its condition matches.
+type Effect string
+
+// Rule effects.
+const (
+	Allow Effect = "allow"
+	Deny  Effect = "deny"
+)
+
+// Algorithm selects how rule matches combine into a decision.
+type Algorithm string
+
+const (
+	// FirstApplicable returns the effect of the first rule whose condition matches.
+	FirstApplicable Algorithm = "first-applicable"
+	// DenyOverrides denies if any deny rule matches, otherwise allows if any allow rule matches.
+	DenyOverrides Algorithm = "deny-overrides"
+)
+
+// Rule is a single declarative policy rule.
+type Rule struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Effect      Effect `json:"effect" yaml:"effect"`
+	Condition   string `json:"condition" yaml:"condition"` // expression; empty matches every request
+
+	expr *Expression
+}
+
+// Policy is an ordered set of rules with a combining algorithm and a default effect.
+type Policy struct {
+	Algorithm Algorithm `json:"algorithm,omitempty" yaml:"algorithm,omitempty"` // defaults to FirstApplicable
+	Default   Effect    `json:"default,omitempty" yaml:"default,omitempty"`     // effect when no rule matches; defaults to Deny
+	Rules     []*Rule   `json:"rules" yaml:"rules"`
+}
+
+// Compile validates the policy, fills in defaults, and compiles every rule condition.
+func (p *Policy) Compile() error {
+	if p.Algorithm == "" {
+		p.Algorithm = FirstApplicable
+	}
+	if p.Algorithm != FirstApplicable && p.Algorithm != DenyOverrides {
+		return fmt.Errorf("policy: unknown algorithm %q", p.Algorithm)
+	}
+	if p.Default == "" {
+		p.Default = Deny
+	}
+	if p.Default != Allow && p.Default != Deny {
+		return fmt.Errorf("policy: invalid default effect %q", p.Default)
+	}
+	for i, r := range p.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i)
+		}
+		if r.Effect != Allow && r.Effect != Deny {
+			return fmt.Errorf("policy: rule %q: invalid effect %q", r.Name, r.Effect)
+		}
+		if strings.TrimSpace(r.Condition) == "" {
+			r.expr = nil
+			continue
+		}
+		expr, err := Compile(r.Condition)
+		if err != nil {
+			return fmt.Errorf("policy: rule %q: %w", r.Name, err)
+		}
+		r.expr = expr
+	}
+	return nil
+}
+
+// LoadJSON reads and compiles a policy from JSON.
+func LoadJSON(r io.Reader) (*Policy, error) {
+	p := &Policy{}
+	if err := json.NewDecoder(r).Decode(p); err != nil {
+		return nil, fmt.Errorf("policy: decode json: %w", err)
+	}
+	return p, p.Compile()
+}
+
+// LoadYAML reads and compiles a policy from YAML.
+func LoadYAML(r io.Reader) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.NewDecoder(r).Decode(p); err != nil {
+		return nil, fmt.Errorf("policy: decode yaml: %w", err)
+	}
+	return p, p.Compile()
+}
+
+// LoadFile reads a policy file, choosing the format from its extension (.json, .yaml, .yml).
+func LoadFile(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return LoadJSON(f)
+	case ".yaml", ".yml":
+		return LoadYAML(f)
+	}
+	return nil, fmt.Errorf("policy: unsupported file extension %q", filepath.Ext(path))
+}
+
+// Decision is the result of evaluating a policy.
+type Decision struct {
+	Allowed bool
+	Effect  Effect
+	Rule    *Rule // matched rule, or nil if the policy default applied
+}
+
+// Reason describes the decision for audit logs.
+func (d Decision) Reason() string {
+	if d.Rule == nil {
+		return "default " + string(d.Effect)
+	}
+	return fmt.Sprintf("rule %q (%s)", d.Rule.Name, d.Effect)
+}
+
+// Input carries the attributes a policy is evaluated against.
+type Input struct {
+	User     core.User
+	Request  *http.Request
+	Resource map[string]interface{}
+}
+
+// Env builds the evaluation environment for in. The user is exposed as "user" with an "id"
+// key plus its attributes, the request as "request" (method, path, host, scheme, remote_ip,
+// headers, query), and the resource as "resource".
+func (in Input) Env() map[string]interface{} {
+	user := map[string]interface{}{}
+	if in.User != nil {
+		for k, v := range in.User.GetAttributes() {
+			user[k] = v
+		}
+		user["id"] = in.User.GetID()
+	}
+	request := map[string]interface{}{}
+	if r := in.Request; r != nil {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		headers := map[string]interface{}{}
+		for k := range r.Header {
+			headers[strings.ToLower(k)] = r.Header.Get(k)
+		}
+		query := map[string]interface{}{}
+		for k := range r.URL.Query() {
+			query[k] = r.URL.Query().Get(k)
+		}
+		request["method"] = r.Method
+		request["path"] = r.URL.Path
+		request["host"] = r.Host
+		request["scheme"] = scheme
+		request["remote_ip"] = core.RemoteIP(r)
+		request["headers"] = headers
+		request["query"] = query
+	}
+	resource := in.Resource
+	if resource == nil {
+		resource = map[string]interface{}{}
+	}
+	return map[string]interface{}{"user": user, "request": request, "resource": resource}
+}
+
+// Evaluate evaluates the policy against in.
+func (p *Policy) Evaluate(in Input) (Decision, error) {
+	env := in.Env()
+	var allowMatch *Rule
+	for _, r := range p.Rules {
+		matched := true
+		if r.expr != nil {
+			ok, err := r.expr.Eval(env)
+			if err != nil {
+				return Decision{Effect: Deny}, fmt.Errorf("policy: rule %q: %w", r.Name, err)
+			}
+			matched = ok
+		}
+		if !matched {
+			continue
+		}
+		if p.Algorithm != DenyOverrides || r.Effect == Deny {
+			return Decision{Allowed: r.Effect == Allow, Effect: r.Effect, Rule: r}, nil
+		}
+		if allowMatch == nil {
+			allowMatch = r
+		}
+	}
+	if allowMatch != nil {
+		return Decision{Allowed: true, Effect: Allow, Rule: allowMatch}, nil
+	}
+	return Decision{Allowed: p.Default == Allow, Effect: p.Default}, nil
+}
+
+// Config holds settings for an Engine.
+type Config struct {
+	Policy *Policy
+	// OnDecision, if set, is called with every decision for auditing.
+	OnDecision func(ctx context.Context, in Input, d Decision, err error)
+}
+
+// Engine evaluates a compiled Policy and reports decisions.
+type Engine struct {
+	config Config
+}
+
+// ErrNoPolicy is returned by New when Config.Policy is nil.
+var ErrNoPolicy = errors.New("policy: no policy configured")
+
+// New creates an Engine, compiling the policy if it has not been compiled yet.
+func New(config Config) (*Engine, error) {
+	if config.Policy == nil {
+		return nil, ErrNoPolicy
+	}
+	if err := config.Policy.Compile(); err != nil {
+		return nil, err
+	}
+	return &Engine{config: config}, nil
+}
+
+// Evaluate evaluates the engine's policy against in and reports the decision to OnDecision.
+// Evaluation errors yield a deny decision alongside the error.
+func (e *Engine) Evaluate(ctx context.Context, in Input) (Decision, error) {
+	d, err := e.config.Policy.Evaluate(in)
+	if e.config.OnDecision != nil {
+		e.config.OnDecision(ctx, in, d, err)
+	}
+	return d, err
+}
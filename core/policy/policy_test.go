@@ -0,0 +1,181 @@
+package policy_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-ez-auth/core/policy"
+)
+
+type dummyUser struct {
+	id    string
+	attrs map[string]interface{}
+}
+
+func (d dummyUser) GetID() string                         { return d.id }
+func (d dummyUser) GetAttributes() map[string]interface{} { return d.attrs }
+
+func TestExpression(t *testing.T) {
+	env := map[string]interface{}{
+		"user":     map[string]interface{}{"id": "u1", "department": "eng", "level": 3, "roles": []string{"dev", "oncall"}},
+		"request":  map[string]interface{}{"method": "GET", "path": "/reports/1"},
+		"resource": map[string]interface{}{"owner_dept": "eng"},
+	}
+	cases := []struct {
+		src  string
+		want bool
+	}{
+		{`user.department == resource.owner_dept && request.method in ["GET","HEAD"]`, true},
+		{`user.department == resource.owner_dept && request.method in ['POST']`, false},
+		{`user.level >= 3 && user.level < 4`, true},
+		{`"oncall" in user.roles`, true},
+		{`"admin" not in user.roles`, true},
+		{`"/reports" in request.path`, true},
+		{`!(user.id == "u2") || false`, true},
+		{`user.missing == null`, true},
+		{`user.missing > 1`, false},
+		{`resource.owner_dept != "sales" && (request.method == "POST" || true)`, true},
+	}
+	for _, tc := range cases {
+		expr, err := policy.Compile(tc.src)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", tc.src, err)
+		}
+		got, err := expr.Eval(env)
+		if err != nil {
+			t.Fatalf("Eval(%q): %v", tc.src, err)
+		}
+		if got != tc.want {
+			t.Errorf("Eval(%q) = %v, want %v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestExpression_MissingAttributes(t *testing.T) {
+	// Neither side has a department: absent data must not satisfy the rule.
+	env := map[string]interface{}{
+		"user":     map[string]interface{}{"id": "u1", "nothing": nil},
+		"request":  map[string]interface{}{"method": "GET"},
+		"resource": map[string]interface{}{},
+	}
+	for src, want := range map[string]bool{
+		`user.department == resource.owner_dept && request.method in ["GET","HEAD"]`: false,
+		`user.department != "sales"`:     false,
+		`user.department in ["eng"]`:     false,
+		`user.department not in ["eng"]`: false,
+		`"eng" in user.departments`:      false,
+		`"eng" not in user.departments`:  false,
+		`user.department == null`:        true,
+		`user.department != null`:        false,
+		`user.nothing == null`:           true,
+		`user.id.sub == null`:            true,
+		`!(user.department == "sales")`:  true,
+		`request.method not in ["POST"]`: true,
+	} {
+		expr, err := policy.Compile(src)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", src, err)
+		}
+		if got, err := expr.Eval(env); err != nil || got != want {
+			t.Errorf("Eval(%q) = %v, %v; want %v", src, got, err, want)
+		}
+	}
+}
+
+func TestExpression_Errors(t *testing.T) {
+	for _, src := range []string{`user.id ==`, `"unterminated`, `(a == b`, `a not b`, `a == b c`, `user. == 1`, `a # b`} {
+		if _, err := policy.Compile(src); err == nil {
+			t.Errorf("Compile(%q): expected error", src)
+		}
+	}
+	expr, _ := policy.Compile(`user.id`)
+	if _, err := expr.Eval(map[string]interface{}{"user": map[string]interface{}{"id": "u1"}}); err == nil {
+		t.Error("expected non-bool result to be an error")
+	}
+}
+
+const yamlPolicy = `
+rules:
+  - name: block-contractors
+    effect: deny
+    condition: user.type == "contractor" && request.method != "GET"
+  - name: same-department
+    effect: allow
+    condition: user.department == resource.owner_dept && request.method in ["GET", "HEAD"]
+`
+
+func TestPolicy_FirstApplicable(t *testing.T) {
+	p, err := policy.LoadYAML(strings.NewReader(yamlPolicy))
+	if err != nil {
+		t.Fatalf("LoadYAML: %v", err)
+	}
+	user := dummyUser{"u1", map[string]interface{}{"department": "eng"}}
+	req := httptest.NewRequest("GET", "/reports/1", nil)
+
+	d, err := p.Evaluate(policy.Input{User: user, Request: req, Resource: map[string]interface{}{"owner_dept": "eng"}})
+	if err != nil || !d.Allowed || d.Rule == nil || d.Rule.Name != "same-department" {
+		t.Fatalf("expected allow by same-department, got %+v %v", d, err)
+	}
+	d, _ = p.Evaluate(policy.Input{User: user, Request: req, Resource: map[string]interface{}{"owner_dept": "sales"}})
+	if d.Allowed || d.Rule != nil || d.Reason() != "default deny" {
+		t.Errorf("expected default deny, got %+v", d)
+	}
+}
+
+func TestPolicy_DenyOverrides(t *testing.T) {
+	src := `{"algorithm": "deny-overrides", "rules": [
+		{"name": "everyone", "effect": "allow"},
+		{"name": "no-delete", "effect": "deny", "condition": "request.method == \"DELETE\""}
+	]}`
+	p, err := policy.LoadJSON(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("LoadJSON: %v", err)
+	}
+	d, _ := p.Evaluate(policy.Input{Request: httptest.NewRequest("DELETE", "/", nil)})
+	if d.Allowed || d.Rule.Name != "no-delete" {
+		t.Errorf("expected deny by no-delete, got %+v", d)
+	}
+	d, _ = p.Evaluate(policy.Input{Request: httptest.NewRequest("GET", "/", nil)})
+	if !d.Allowed || d.Rule.Name != "everyone" {
+		t.Errorf("expected allow by everyone, got %+v", d)
+	}
+}
+
+func TestPolicy_Invalid(t *testing.T) {
+	for _, src := range []string{
+		`{"rules": [{"name": "x", "effect": "maybe"}]}`,
+		`{"rules": [{"name": "x", "effect": "allow", "condition": "a =="}]}`,
+		`{"algorithm": "random", "rules": []}`,
+	} {
+		if _, err := policy.LoadJSON(strings.NewReader(src)); err == nil {
+			t.Errorf("expected error for %s", src)
+		}
+	}
+}
+
+func TestLoadFile_AndEngine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yml")
+	if err := os.WriteFile(path, []byte(yamlPolicy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := policy.LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	var audited []policy.Decision
+	engine, err := policy.New(policy.Config{Policy: p, OnDecision: func(ctx context.Context, in policy.Input, d policy.Decision, err error) {
+		audited = append(audited, d)
+	}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	user := dummyUser{"u1", map[string]interface{}{"type": "contractor"}}
+	d, _ := engine.Evaluate(context.Background(), policy.Input{User: user, Request: httptest.NewRequest("POST", "/", nil)})
+	if d.Allowed || len(audited) != 1 || audited[0].Rule.Name != "block-contractors" {
+		t.Errorf("expected audited deny by block-contractors, got %+v %v", d, audited)
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// AuthResult describes a successful authentication: who the user is and how, when, and with
+// which credential they authenticated.
+type AuthResult struct {
+	User         User
+	Strategy     string    // name of the strategy that authenticated the request
+	Methods      []string  // authentication methods used, in the spirit of the OIDC "amr" claim (e.g. "pwd", "jwt")
+	AuthTime     time.Time // when the user authenticated; for tokens and sessions this may predate the request
+	ExpiresAt    time.Time // when the credential expires; zero if unknown or non-expiring
+	CredentialID string    // non-secret identifier of the credential (token ID, key fingerprint); may be empty
+	Actor        User      // the real user when User is being impersonated; nil otherwise
+}
+
+// ResultStrategy is implemented by strategies that can describe their authentications in
+// more detail than a User. The Authenticator prefers AuthenticateResult when it is available.
+type ResultStrategy interface {
+	Strategy
+	AuthenticateResult(ctx context.Context, r *http.Request) (*AuthResult, error)
+}
+
+// authenticateResult runs strat and fills in defaults for anything it did not report.
+func authenticateResult(name string, strat Strategy, r *http.Request) (*AuthResult, error) {
+	var res *AuthResult
+	if rs, ok := strat.(ResultStrategy); ok {
+		var err error
+		if res, err = rs.AuthenticateResult(r.Context(), r); err != nil {
+			return nil, err
+		}
+	} else {
+		user, err := strat.Authenticate(r.Context(), r)
+		if err != nil {
+			return nil, err
+		}
+		res = &AuthResult{User: user}
+	}
+	if res.Strategy == "" {
+		res.Strategy = name
+	}
+	if len(res.Methods) == 0 {
+		res.Methods = []string{name}
+	}
+	if res.AuthTime.IsZero() {
+		res.AuthTime = time.Now()
+	}
+	return res, nil
+}
+
+// ContextWithResult returns a copy of ctx carrying res and its User.
+func ContextWithResult(ctx context.Context, res *AuthResult) context.Context {
+	ctx = context.WithValue(ctx, resultContextKey, res)
+	return ContextWithUser(ctx, res.User)
+}
+
+// ResultFromContext retrieves the AuthResult stored by ContextWithResult.
+func ResultFromContext(ctx context.Context) (*AuthResult, bool) {
+	res, ok := ctx.Value(resultContextKey).(*AuthResult)
+	return res, ok
+}
+
+// ActorFromContext returns the user actually making the request: the impersonating actor if the
+// request is impersonated, otherwise the same user as UserFromContext.
+func ActorFromContext(ctx context.Context) (User, bool) {
+	if res, ok := ResultFromContext(ctx); ok && res.Actor != nil {
+		return res.Actor, true
+	}
+	return UserFromContext(ctx)
+}
+
+// IsImpersonated reports whether the request carrying ctx is made by one user acting as another.
+func IsImpersonated(ctx context.Context) bool {
+	res, ok := ResultFromContext(ctx)
+	return ok && res.Actor != nil
+}
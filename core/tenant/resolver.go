@@ -0,0 +1,96 @@
+package tenant
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Resolver extracts a tenant ID from a request. An empty ID means the request names no tenant.
+type Resolver interface {
+	Resolve(r *http.Request) (string, error)
+}
+
+// ResolverFunc adapts a function to the Resolver interface.
+type ResolverFunc func(r *http.Request) (string, error)
+
+// Resolve calls f(r).
+func (f ResolverFunc) Resolve(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// HostResolver resolves tenants from the request host, either by exact match in Hosts
+// (e.g. "auth.acme.com" -> "acme") or as the subdomain of BaseDomain
+// (e.g. "acme.example.com" with BaseDomain "example.com" -> "acme").
+type HostResolver struct {
+	Hosts      map[string]string
+	BaseDomain string
+}
+
+// Resolve returns the tenant ID for r's host.
+func (h HostResolver) Resolve(r *http.Request) (string, error) {
+	host := strings.ToLower(r.Host)
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	if id, ok := h.Hosts[host]; ok {
+		return id, nil
+	}
+	if h.BaseDomain != "" {
+		suffix := "." + strings.ToLower(strings.TrimPrefix(h.BaseDomain, "."))
+		if sub, ok := strings.CutSuffix(host, suffix); ok && sub != "" && !strings.Contains(sub, ".") {
+			return sub, nil
+		}
+	}
+	return "", nil
+}
+
+// PathPrefixResolver resolves tenants from the first path segment after Prefix,
+// e.g. "/t/acme/orders" with Prefix "/t/" -> "acme".
+type PathPrefixResolver struct {
+	Prefix string // defaults to "/"
+}
+
+// Resolve returns the tenant ID from r's path.
+func (p PathPrefixResolver) Resolve(r *http.Request) (string, error) {
+	prefix := p.Prefix
+	if prefix == "" {
+		prefix = "/"
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, prefix)
+	if !ok {
+		return "", nil
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	return id, nil
+}
+
+// HeaderResolver resolves tenants from a request header.
+type HeaderResolver struct {
+	Header string // defaults to "X-Tenant-ID"
+}
+
+// Resolve returns the tenant ID from r's header.
+func (h HeaderResolver) Resolve(r *http.Request) (string, error) {
+	name := h.Header
+	if name == "" {
+		name = "X-Tenant-ID"
+	}
+	return strings.TrimSpace(r.Header.Get(name)), nil
+}
+
+// FirstOf returns a Resolver that tries each resolver in order and returns the first non-empty ID.
+func FirstOf(resolvers ...Resolver) Resolver {
+	return ResolverFunc(func(r *http.Request) (string, error) {
+		for _, res := range resolvers {
+			id, err := res.Resolve(r)
+			if err != nil {
+				return "", err
+			}
+			if id != "" {
+				return id, nil
+			}
+		}
+		return "", nil
+	})
+}
@@ -0,0 +1,147 @@
+// Package tenant adds multi-tenant authentication to go-ez-auth. A Resolver extracts the
+// tenant ID from each request (host, path prefix, or header), and a Manager maps tenant
+// IDs to a Tenant holding that tenant's own Authenticator, strategies, and UserStore.
+// The resolved tenant is exposed in the request context.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go-ez-auth/core"
+)
+
+// Errors returned while resolving a tenant.
+var (
+	ErrNoTenant      = errors.New("tenant: no tenant in request")
+	ErrUnknownTenant = errors.New("tenant: unknown tenant")
+)
+
+// Tenant binds a tenant ID to its own configured strategies and user store.
+type Tenant struct {
+	ID            string
+	Authenticator *core.Authenticator // strategies configured for this tenant (issuer, OAuth2 client, key set)
+	Strategies    []string            // strategy names to try; empty uses the middleware's Strategies
+	UserStore     core.UserStore      // optional; the tenant's user backend, for application use
+}
+
+// Config holds settings for a Manager.
+type Config struct {
+	Resolver Resolver
+	Tenants  []*Tenant
+}
+
+// Manager resolves requests to tenants. It is safe for concurrent use, so tenants may be
+// added and removed while requests are served.
+type Manager struct {
+	resolver Resolver
+
+	mu      sync.RWMutex
+	tenants map[string]*Tenant
+}
+
+// New creates a Manager from Config.
+func New(config Config) (*Manager, error) {
+	if config.Resolver == nil {
+		return nil, fmt.Errorf("tenant: Resolver is required: %w", core.ErrInvalidConfig)
+	}
+	m := &Manager{resolver: config.Resolver, tenants: make(map[string]*Tenant)}
+	for _, t := range config.Tenants {
+		if err := m.Add(t); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Add registers t, replacing any tenant with the same ID and closing the replaced tenant's
+// Authenticator unless t shares it.
+func (m *Manager) Add(t *Tenant) error {
+	if t.ID == "" {
+		return fmt.Errorf("tenant: ID is required: %w", core.ErrInvalidConfig)
+	}
+	if t.Authenticator == nil {
+		return fmt.Errorf("tenant %q: Authenticator is required: %w", t.ID, core.ErrInvalidConfig)
+	}
+	m.mu.Lock()
+	old, ok := m.tenants[t.ID]
+	m.tenants[t.ID] = t
+	m.mu.Unlock()
+	if !ok || old.Authenticator == t.Authenticator {
+		return nil
+	}
+	return old.Authenticator.Close()
+}
+
+// Remove unregisters the tenant with the given ID and closes its Authenticator.
+func (m *Manager) Remove(id string) error {
+	m.mu.Lock()
+	t, ok := m.tenants[id]
+	delete(m.tenants, id)
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return t.Authenticator.Close()
+}
+
+// Tenant returns the tenant with the given ID.
+func (m *Manager) Tenant(id string) (*Tenant, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.tenants[id]
+	return t, ok
+}
+
+// Resolve determines the tenant for r. It returns ErrNoTenant if the request names no tenant
+// and ErrUnknownTenant if it names one that is not registered.
+func (m *Manager) Resolve(r *http.Request) (*Tenant, error) {
+	id, err := m.resolver.Resolve(r)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, ErrNoTenant
+	}
+	t, ok := m.Tenant(id)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownTenant, id)
+	}
+	return t, nil
+}
+
+// Close closes the Authenticator of every tenant.
+func (m *Manager) Close() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var errs []error
+	for _, t := range m.tenants {
+		errs = append(errs, t.Authenticator.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// contextKey is the unexported type for context keys defined in this package.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying t.
+func NewContext(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext retrieves the Tenant stored by NewContext.
+func FromContext(ctx context.Context) (*Tenant, bool) {
+	t, ok := ctx.Value(contextKey{}).(*Tenant)
+	return t, ok
+}
+
+// IDFromContext returns the ID of the tenant stored in ctx, or "" if there is none.
+func IDFromContext(ctx context.Context) string {
+	if t, ok := FromContext(ctx); ok {
+		return t.ID
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package tenant_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-ez-auth/core"
+	"go-ez-auth/core/tenant"
+)
+
+func TestResolvers(t *testing.T) {
+	cases := []struct {
+		name     string
+		resolver tenant.Resolver
+		host     string
+		path     string
+		header   string
+		want     string
+	}{
+		{"host map", tenant.HostResolver{Hosts: map[string]string{"auth.acme.com": "acme"}}, "auth.acme.com:8443", "/", "", "acme"},
+		{"subdomain", tenant.HostResolver{BaseDomain: "example.com"}, "Globex.example.com", "/", "", "globex"},
+		{"nested subdomain", tenant.HostResolver{BaseDomain: "example.com"}, "a.b.example.com", "/", "", ""},
+		{"bare base domain", tenant.HostResolver{BaseDomain: "example.com"}, "example.com", "/", "", ""},
+		{"path prefix", tenant.PathPrefixResolver{Prefix: "/t/"}, "x", "/t/acme/orders", "", "acme"},
+		{"path no prefix", tenant.PathPrefixResolver{Prefix: "/t/"}, "x", "/orders", "", ""},
+		{"header", tenant.HeaderResolver{}, "x", "/", "initech", "initech"},
+		{"first of", tenant.FirstOf(tenant.HeaderResolver{}, tenant.PathPrefixResolver{}), "x", "/acme/x", "", "acme"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Host = tc.host
+		if tc.header != "" {
+			req.Header.Set("X-Tenant-ID", tc.header)
+		}
+		got, err := tc.resolver.Resolve(req)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: expected %q, got %q %v", tc.name, tc.want, got, err)
+		}
+	}
+}
+
+// closingStrategy records whether it was closed.
+type closingStrategy struct{ closed *bool }
+
+func (closingStrategy) Name() string { return "closing" }
+func (closingStrategy) Setup() error { return nil }
+func (closingStrategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	return nil, core.ErrNoCredentials
+}
+func (c closingStrategy) Close() error {
+	*c.closed = true
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	acme := &tenant.Tenant{ID: "acme", Authenticator: core.NewAuthenticator()}
+	m, err := tenant.New(tenant.Config{Resolver: tenant.HeaderResolver{}, Tenants: []*tenant.Tenant{acme}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	if _, err := m.Resolve(req); !errors.Is(err, tenant.ErrNoTenant) {
+		t.Errorf("expected ErrNoTenant, got %v", err)
+	}
+	req.Header.Set("X-Tenant-ID", "globex")
+	if _, err := m.Resolve(req); !errors.Is(err, tenant.ErrUnknownTenant) {
+		t.Errorf("expected ErrUnknownTenant, got %v", err)
+	}
+	req.Header.Set("X-Tenant-ID", "acme")
+	if got, err := m.Resolve(req); err != nil || got != acme {
+		t.Errorf("expected acme, got %v %v", got, err)
+	}
+
+	if err := m.Add(&tenant.Tenant{ID: "bad"}); !errors.Is(err, core.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for tenant without Authenticator, got %v", err)
+	}
+	// Replacing a tenant closes the Authenticator it no longer uses.
+	var closed bool
+	old := core.NewAuthenticator()
+	old.Register(closingStrategy{&closed})
+	if err := m.Add(&tenant.Tenant{ID: "initech", Authenticator: old}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := m.Add(&tenant.Tenant{ID: "initech", Authenticator: old, Strategies: []string{"closing"}}); err != nil || closed {
+		t.Errorf("expected a shared Authenticator to stay open, got %v closed=%v", err, closed)
+	}
+	if err := m.Add(&tenant.Tenant{ID: "initech", Authenticator: core.NewAuthenticator()}); err != nil || !closed {
+		t.Errorf("expected the replaced Authenticator to be closed, got %v closed=%v", err, closed)
+	}
+
+	if err := m.Remove("acme"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, ok := m.Tenant("acme"); ok {
+		t.Error("expected acme to be removed")
+	}
+}
@@ -0,0 +1,83 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store backed by an in-process map. Expired entries are dropped lazily
+// and swept periodically. A Limiter using a MemoryStore sets its clock to the Limiter's Now.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+type memoryEntry struct {
+	state   State
+	expires time.Time
+}
+
+// sweepInterval is how often Set removes expired entries.
+const sweepInterval = time.Minute
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+// setClock makes the store judge expiry by now.
+func (s *MemoryStore) setClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// Get returns the state for key, or a zero State if there is none or it expired.
+func (s *MemoryStore) Get(ctx context.Context, key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(key, s.now()), nil
+}
+
+// get returns the live state for key at now, dropping it if it expired. s.mu must be held.
+func (s *MemoryStore) get(key string, now time.Time) State {
+	e, ok := s.entries[key]
+	if !ok {
+		return State{}
+	}
+	if !now.Before(e.expires) {
+		delete(s.entries, key)
+		return State{}
+	}
+	return e.state
+}
+
+// Update applies fn to the state for key under the store's lock and keeps the result until the
+// returned ttl elapses.
+func (s *MemoryStore) Update(ctx context.Context, key string, fn func(State) (State, time.Duration)) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.After(s.nextSweep) {
+		for k, e := range s.entries {
+			if !now.Before(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.nextSweep = now.Add(sweepInterval)
+	}
+	st, ttl := fn(s.get(key, now))
+	s.entries[key] = memoryEntry{state: st, expires: now.Add(ttl)}
+	return st, nil
+}
+
+// Delete removes the state for key.
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
@@ -0,0 +1,194 @@
+// Package throttle limits repeated authentication failures. A Limiter counts failed attempts
+// per key (a username, an API key, a client IP) within a window and locks a key out
+// once it reaches the limit. Consecutive lockouts of the same key double in length, up to a
+// maximum. Counters live in a Store: NewMemoryStore for a single process, or a shared
+// backend implementing Store for a fleet of servers.
+package throttle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-ez-auth/core"
+)
+
+// State is the failure history of one key.
+type State struct {
+	Failures    int       // failures in the current window
+	WindowStart time.Time // when the current window began
+	Lockouts    int       // consecutive lockouts, which drive the backoff
+	LockedUntil time.Time // end of the current lockout; zero if never locked
+}
+
+// Store persists State by key. Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the state for key, or a zero State if there is none.
+	Get(ctx context.Context, key string) (State, error)
+	// Update atomically replaces the state for key (a zero State if there is none) with the result
+	// of fn, which may be discarded after the returned ttl. Concurrent updates of one key must not
+	// be lost, so a shared backend needs a transaction or compare-and-swap loop; fn may be retried.
+	Update(ctx context.Context, key string, fn func(State) (State, time.Duration)) (State, error)
+	// Delete removes the state for key.
+	Delete(ctx context.Context, key string) error
+}
+
+// Config holds the Limiter settings.
+type Config struct {
+	Store       Store            // defaults to NewMemoryStore()
+	MaxFailures int              // failures within Window that trigger a lockout; defaults to 5
+	Window      time.Duration    // period over which failures are counted; defaults to 15 minutes
+	Lockout     time.Duration    // length of the first lockout, doubled for each consecutive one; defaults to 1 minute
+	MaxLockout  time.Duration    // upper bound on a lockout; defaults to 1 hour
+	Now         func() time.Time // defaults to time.Now; also drives expiry in a MemoryStore
+}
+
+// Limiter tracks failed attempts and lockouts. A nil *Limiter allows everything, so strategies
+// can call it unconditionally.
+type Limiter struct {
+	config Config
+}
+
+// New creates a Limiter with defaults.
+func New(config Config) *Limiter {
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if m, ok := config.Store.(*MemoryStore); ok {
+		m.setClock(config.Now)
+	}
+	if config.MaxFailures <= 0 {
+		config.MaxFailures = 5
+	}
+	if config.Window <= 0 {
+		config.Window = 15 * time.Minute
+	}
+	if config.Lockout <= 0 {
+		config.Lockout = time.Minute
+	}
+	if config.MaxLockout <= 0 {
+		config.MaxLockout = time.Hour
+	}
+	if config.MaxLockout < config.Lockout {
+		config.MaxLockout = config.Lockout
+	}
+	return &Limiter{config: config}
+}
+
+// Check returns a *core.ThrottledError if any of keys is locked out, reporting the longest
+// remaining lockout. Errors from the Store are returned as is.
+func (l *Limiter) Check(ctx context.Context, keys ...string) error {
+	if l == nil {
+		return nil
+	}
+	now := l.config.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		st, err := l.config.Store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if d := st.LockedUntil.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		return &core.ThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Fail records a failed attempt for each of keys, locking out those that reach MaxFailures.
+// Strategies may ignore its error, since the attempt is rejected either way.
+func (l *Limiter) Fail(ctx context.Context, keys ...string) error {
+	if l == nil {
+		return nil
+	}
+	now := l.config.Now()
+	for _, key := range keys {
+		if _, err := l.config.Store.Update(ctx, key, func(st State) (State, time.Duration) {
+			return l.fail(st, now)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fail returns st after one more failure at now, and how long it must be kept.
+func (l *Limiter) fail(st State, now time.Time) (State, time.Duration) {
+	if st.WindowStart.IsZero() || now.Sub(st.WindowStart) >= l.config.Window {
+		st.Failures, st.WindowStart = 0, now
+	}
+	st.Failures++
+	if st.Failures < l.config.MaxFailures {
+		return st, l.config.Window
+	}
+	d := l.lockout(st.Lockouts)
+	st.Lockouts++
+	st.LockedUntil = now.Add(d)
+	st.Failures, st.WindowStart = 0, time.Time{}
+	// Remember the lockout count for a window past the lockout so a repeat offender backs off further.
+	return st, d + l.config.Window
+}
+
+// Reason classifies an error returned by Check: ReasonThrottled for a lockout, ReasonStoreError
+// for a Store failure.
+func Reason(err error) core.Reason {
+	if errors.Is(err, core.ErrThrottled) {
+		return core.ReasonThrottled
+	}
+	return core.ReasonStoreError
+}
+
+// lockout returns the length of the lockout following n earlier consecutive ones.
+func (l *Limiter) lockout(n int) time.Duration {
+	d := l.config.Lockout
+	for i := 0; i < n && d < l.config.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > l.config.MaxLockout {
+		d = l.config.MaxLockout
+	}
+	return d
+}
+
+// Reset clears the history of each of keys, typically after a successful login.
+func (l *Limiter) Reset(ctx context.Context, keys ...string) error {
+	if l == nil {
+		return nil
+	}
+	for _, key := range keys {
+		if err := l.config.Store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UserKey returns the key for a username presented to the named strategy. Usernames are
+// compared case-insensitively so that case variations share one counter.
+func UserKey(strategy, username string) string {
+	return strategy + ":user:" + strings.ToLower(username)
+}
+
+// APIKeyKey returns the key for an API key presented to the named strategy, built from a
+// digest of the whole key so the secret is never stored. Keys sharing an issuer prefix such as
+// "sk_live_" get separate counters, so failures with bogus keys cannot lock out valid ones.
+func APIKeyKey(strategy, apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return strategy + ":key:" + hex.EncodeToString(sum[:16])
+}
+
+// IPKey returns the key for the client address of r, shared by all strategies. It uses
+// r.RemoteAddr; behind a proxy, rewrite RemoteAddr from a trusted forwarding header first.
+func IPKey(r *http.Request) string {
+	return "ip:" + core.RemoteIP(r)
+}
@@ -0,0 +1,130 @@
+package throttle_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-ez-auth/core"
+	"go-ez-auth/core/throttle"
+)
+
+func TestLimiter_LockoutAndBackoff(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := throttle.New(throttle.Config{
+		MaxFailures: 3,
+		Window:      time.Minute,
+		Lockout:     10 * time.Second,
+		MaxLockout:  30 * time.Second,
+		Now:         func() time.Time { return now },
+	})
+	ctx := context.Background()
+
+	lockOut := func() {
+		t.Helper()
+		for i := 0; i < 3; i++ {
+			if err := l.Check(ctx, "k"); err != nil {
+				t.Fatalf("attempt %d: unexpected %v", i, err)
+			}
+			l.Fail(ctx, "k")
+		}
+	}
+	expectLocked := func(want time.Duration) {
+		t.Helper()
+		var te *core.ThrottledError
+		if err := l.Check(ctx, "k"); !errors.As(err, &te) || te.RetryAfter != want {
+			t.Fatalf("expected lockout of %s, got %v", want, err)
+		}
+	}
+
+	lockOut()
+	expectLocked(10 * time.Second)
+	now = now.Add(10 * time.Second)
+	lockOut()
+	expectLocked(20 * time.Second)
+	now = now.Add(20 * time.Second)
+	lockOut()
+	expectLocked(30 * time.Second) // capped at MaxLockout
+
+	// Reset clears the history.
+	l.Reset(ctx, "k")
+	if err := l.Check(ctx, "k"); err != nil {
+		t.Errorf("expected no lockout after Reset, got %v", err)
+	}
+}
+
+func TestLimiter_WindowExpires(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := throttle.New(throttle.Config{MaxFailures: 2, Window: time.Minute, Now: func() time.Time { return now }})
+	ctx := context.Background()
+
+	l.Fail(ctx, "k")
+	now = now.Add(2 * time.Minute)
+	l.Fail(ctx, "k")
+	if err := l.Check(ctx, "k"); err != nil {
+		t.Errorf("expected failures in separate windows not to lock, got %v", err)
+	}
+	l.Fail(ctx, "k")
+	if err := l.Check(ctx, "k"); !errors.Is(err, core.ErrThrottled) {
+		t.Errorf("expected ErrThrottled, got %v", err)
+	}
+}
+
+func TestLimiter_ConcurrentFailures(t *testing.T) {
+	store := throttle.NewMemoryStore()
+	l := throttle.New(throttle.Config{Store: store, MaxFailures: 1000})
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Fail(ctx, "k")
+		}()
+	}
+	wg.Wait()
+	if st, _ := store.Get(ctx, "k"); st.Failures != 200 {
+		t.Errorf("expected every concurrent failure to be counted, got %d", st.Failures)
+	}
+}
+
+func TestMemoryStore_UsesLimiterClock(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := throttle.NewMemoryStore()
+	l := throttle.New(throttle.Config{Store: store, Window: time.Minute, Now: func() time.Time { return now }})
+	ctx := context.Background()
+
+	l.Fail(ctx, "k")
+	now = now.Add(time.Minute)
+	if st, _ := store.Get(ctx, "k"); st != (throttle.State{}) {
+		t.Errorf("expected the entry to expire by the injected clock, got %+v", st)
+	}
+}
+
+func TestLimiter_Nil(t *testing.T) {
+	var l *throttle.Limiter
+	if err := l.Fail(context.Background(), "k"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Check(context.Background(), "k"); err != nil {
+		t.Errorf("expected nil Limiter to allow, got %v", err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	if got := throttle.UserKey("local", "Alice"); got != "local:user:alice" {
+		t.Errorf("unexpected user key %q", got)
+	}
+	if a, b := throttle.APIKeyKey("apikey", "sk_live_0123456789"), throttle.APIKeyKey("apikey", "sk_live_9876543210"); a == b || strings.Contains(a, "0123456789") {
+		t.Errorf("expected distinct keys that do not contain the secret, got %q and %q", a, b)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.7:5555"
+	if got := throttle.IPKey(req); got != "ip:203.0.113.7" {
+		t.Errorf("unexpected ip key %q", got)
+	}
+}
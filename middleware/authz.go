@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+	"go-ez-auth/core"
+	"go-ez-auth/core/authz"
+)
+
+// authzCheck decides whether an authenticated user may proceed.
+type authzCheck func(ctx context.Context, user core.User) (bool, error)
+
+// anyRole builds a check requiring at least one of roles.
+func anyRole(az *authz.Authorizer, roles []string) authzCheck {
+	return func(ctx context.Context, user core.User) (bool, error) {
+		return az.HasAnyRole(ctx, user, roles...)
+	}
+}
+
+// allPermissions builds a check requiring every one of permissions.
+func allPermissions(az *authz.Authorizer, permissions []string) authzCheck {
+	return func(ctx context.Context, user core.User) (bool, error) {
+		return az.HasAllPermissions(ctx, user, permissions...)
+	}
+}
+
+// authorize runs check for user and returns the error to reject with, or nil to proceed:
+// core.ErrUnauthorized without a user, core.ErrForbidden when denied, or the check's own error.
+// An anonymous user that is denied gets core.ErrUnauthorized, since authenticating might help.
+func authorize(ctx context.Context, user core.User, ok bool, check authzCheck) error {
+	if !ok || user == nil {
+		return core.ErrUnauthorized
+	}
+	allowed, err := check(ctx, user)
+	if err != nil {
+		return fmt.Errorf("middleware: authorization check: %w", err)
+	}
+	if !allowed {
+		if core.IsAnonymous(user) {
+			return core.ErrUnauthorized
+		}
+		return core.ErrForbidden
+	}
+	return nil
+}
+
+// RequireRole returns a net/http middleware allowing users that hold at least one of roles.
+// It must run after an authentication middleware.
+func RequireRole(az *authz.Authorizer, roles ...string) func(http.Handler) http.Handler {
+	return requireNetHTTP(anyRole(az, roles))
+}
+
+// RequirePermission returns a net/http middleware allowing users granted every one of permissions.
+// It must run after an authentication middleware.
+func RequirePermission(az *authz.Authorizer, permissions ...string) func(http.Handler) http.Handler {
+	return requireNetHTTP(allPermissions(az, permissions))
+}
+
+func requireNetHTTP(check authzCheck) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := core.UserFromContext(r.Context())
+			if err := authorize(r.Context(), user, ok, check); err != nil {
+				errorHandlerFrom(r.Context(), PlainErrors)(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GinRequireRole is the Gin variant of RequireRole.
+func GinRequireRole(az *authz.Authorizer, roles ...string) gin.HandlerFunc {
+	return requireGin(anyRole(az, roles))
+}
+
+// GinRequirePermission is the Gin variant of RequirePermission.
+func GinRequirePermission(az *authz.Authorizer, permissions ...string) gin.HandlerFunc {
+	return requireGin(allPermissions(az, permissions))
+}
+
+func requireGin(check authzCheck) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := UserFromGin(c)
+		if err := authorize(c.Request.Context(), user, ok, check); err != nil {
+			errorHandlerFrom(c.Request.Context(), JSONErrors)(c.Writer, c.Request, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// EchoRequireRole is the Echo variant of RequireRole.
+func EchoRequireRole(az *authz.Authorizer, roles ...string) echo.MiddlewareFunc {
+	return requireEcho(anyRole(az, roles))
+}
+
+// EchoRequirePermission is the Echo variant of RequirePermission.
+func EchoRequirePermission(az *authz.Authorizer, permissions ...string) echo.MiddlewareFunc {
+	return requireEcho(allPermissions(az, permissions))
+}
+
+func requireEcho(check authzCheck) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, ok := UserFromEcho(c)
+			if err := authorize(c.Request().Context(), user, ok, check); err != nil {
+				errorHandlerFrom(c.Request().Context(), JSONErrors)(c.Response(), c.Request(), err)
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+	"go-ez-auth/core"
+	"go-ez-auth/core/authz"
+	"go-ez-auth/middleware"
+	"go-ez-auth/stores"
+	"go-ez-auth/strategies/apikey"
+)
+
+type roleUser struct {
+	id    string
+	roles []string
+}
+
+func (u roleUser) GetID() string { return u.id }
+func (u roleUser) GetAttributes() map[string]interface{} {
+	return map[string]interface{}{"roles": u.roles}
+}
+
+func newAuthzFixture(t *testing.T) (middleware.Config, *authz.Authorizer) {
+	a := core.NewAuthenticator()
+	a.Register(apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(map[string]core.User{
+		"admin-key":  roleUser{"admin", []string{"admin"}},
+		"viewer-key": roleUser{"viewer", []string{"viewer"}},
+	})}))
+	model, err := authz.NewModel(
+		authz.Role{Name: "viewer", Permissions: []string{"reports:read"}},
+		authz.Role{Name: "admin", Permissions: []string{"*"}, Inherits: []string{"viewer"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return middleware.Config{Authenticator: a, Strategies: []string{"apikey"}}, authz.New(authz.Config{Model: model})
+}
+
+func TestRequireRole_NetHTTP(t *testing.T) {
+	cfg, az := newAuthzFixture(t)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := middleware.New(cfg)(middleware.RequireRole(az, "admin")(ok))
+
+	cases := map[string]int{"admin-key": http.StatusOK, "viewer-key": http.StatusForbidden}
+	for key, want := range cases {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-API-Key", key)
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", key, want, rr.Code)
+		}
+	}
+
+	// Without an authentication middleware in front, the request is unauthenticated.
+	rr := httptest.NewRecorder()
+	middleware.RequirePermission(az, "reports:read")(ok).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without user, got %d", rr.Code)
+	}
+}
+
+func TestRequirePermission_Gin(t *testing.T) {
+	cfg, az := newAuthzFixture(t)
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(middleware.NewGin(cfg), middleware.GinRequirePermission(az, "reports:read"))
+	e.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", "viewer-key")
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+}
+
+func TestRequirePermission_Echo(t *testing.T) {
+	cfg, az := newAuthzFixture(t)
+	e := echo.New()
+	e.Use(middleware.NewEcho(cfg), middleware.EchoRequirePermission(az, "reports:delete"))
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", "viewer-key")
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go-ez-auth/core"
+	"go-ez-auth/core/tenant"
+)
+
+// Config holds settings shared by the net/http, Gin, and Echo adapters.
+type Config struct {
+	Authenticator *core.Authenticator // strategy set to use; defaults to core.DefaultAuthenticator()
+	Strategies    []string            // strategy names tried in order
+	ChainMode     core.ChainMode      // whether an invalid credential stops the chain; defaults to core.StopOnInvalid
+
+	// Tenants, if set, resolves each request to a tenant whose Authenticator (and Strategies,
+	// if it lists any) replace the ones above. The tenant is stored in the request context.
+	Tenants *tenant.Manager
+
+	// OnError, if set, receives the detailed authentication error (a *core.ChainError) for
+	// logging and alerting. Clients only ever see a generic message.
+	OnError func(r *http.Request, err error)
+
+	// ErrorHandler renders failures, for this middleware and for the authorization middleware
+	// (RequireRole, RequirePolicy, ...) running after it. It defaults to PlainErrors for net/http
+	// and JSONErrors for Gin and Echo; ProblemErrors renders RFC 7807 problem details.
+	ErrorHandler ErrorHandler
+}
+
+// authenticator returns the configured Authenticator or the default one.
+func (c Config) authenticator() *core.Authenticator {
+	if c.Authenticator == nil {
+		return core.DefaultAuthenticator()
+	}
+	return c.Authenticator
+}
+
+// authenticate runs the configured strategies against r and reports failures to OnError.
+// The returned request carries the resolved tenant, if any, and the result in its context.
+func (c Config) authenticate(r *http.Request) (*http.Request, *core.AuthResult, error) {
+	r, res, err := c.run(r)
+	if err != nil {
+		c.reportError(r, err)
+		return r, nil, err
+	}
+	return r, res, nil
+}
+
+// authenticateOptional is like authenticate but lets requests without credentials through as
+// core.Anonymous, stored in the returned request's context with a nil result. Requests that
+// present an invalid credential still fail.
+func (c Config) authenticateOptional(r *http.Request) (*http.Request, core.User, error) {
+	r, res, err := c.run(r)
+	if err == nil {
+		return r, res.User, nil
+	}
+	var chainErr *core.ChainError
+	if errors.As(err, &chainErr) && chainErr.Invalid() == nil {
+		return c.withErrorHandler(r.WithContext(core.ContextWithUser(r.Context(), core.Anonymous))), core.Anonymous, nil
+	}
+	c.reportError(r, err)
+	return r, nil, err
+}
+
+// run resolves the tenant and runs the strategy chain.
+func (c Config) run(r *http.Request) (*http.Request, *core.AuthResult, error) {
+	authenticator, strategies := c.authenticator(), c.Strategies
+	if c.Tenants != nil {
+		t, err := c.Tenants.Resolve(r)
+		if err != nil {
+			return r, nil, &core.ChainError{Errors: []*core.AuthError{core.NewAuthError("tenant", core.ReasonUnknownTenant, err)}}
+		}
+		r = r.WithContext(tenant.NewContext(r.Context(), t))
+		authenticator = t.Authenticator
+		if len(t.Strategies) > 0 {
+			strategies = t.Strategies
+		}
+	}
+	res, err := authenticator.AuthenticateChain(r, c.ChainMode, strategies...)
+	if err != nil {
+		return r, nil, err
+	}
+	return c.withErrorHandler(r.WithContext(core.ContextWithResult(r.Context(), res))), res, nil
+}
+
+// reportError passes err to OnError, if set.
+func (c Config) reportError(r *http.Request, err error) {
+	if c.OnError != nil {
+		c.OnError(r, err)
+	}
+}
+
+// fail sets the failure headers for err and renders it with the configured ErrorHandler, or def
+// if none is configured.
+func (c Config) fail(w http.ResponseWriter, r *http.Request, err error, def ErrorHandler) {
+	setFailureHeaders(w.Header(), err)
+	h := c.ErrorHandler
+	if h == nil {
+		h = def
+	}
+	h(w, r, err)
+}
+
+// withErrorHandler records the configured ErrorHandler in r's context so that authorization
+// middleware running later renders its failures the same way.
+func (c Config) withErrorHandler(r *http.Request) *http.Request {
+	if c.ErrorHandler == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), errorHandlerKey{}, c.ErrorHandler))
+}
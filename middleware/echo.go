@@ -1,22 +1,62 @@
 package middleware
 
 import (
-	"net/http"
-
 	"github.com/labstack/echo/v4"
 	"go-ez-auth/core"
 )
 
-// EchoMiddleware returns an Echo middleware enforcing authentication via strategyNames.
+// EchoMiddleware returns an Echo middleware enforcing authentication via strategyNames
+// of the default Authenticator.
 func EchoMiddleware(strategyNames ...string) echo.MiddlewareFunc {
+	return NewEcho(Config{Strategies: strategyNames})
+}
+
+// NewEcho returns an Echo middleware enforcing authentication as described by cfg.
+func NewEcho(cfg Config) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			user, err := AuthenticateRequest(strategyNames, c.Request())
+			r, res, err := cfg.authenticate(c.Request())
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+				cfg.fail(c.Response(), c.Request(), err, JSONErrors)
+				return nil
+			}
+			c.Set(core.ContextUserKey, res.User)
+			c.SetRequest(r)
+			return next(c)
+		}
+	}
+}
+
+// EchoOptionalMiddleware is the Echo variant of OptionalMiddleware.
+func EchoOptionalMiddleware(strategyNames ...string) echo.MiddlewareFunc {
+	return NewEchoOptional(Config{Strategies: strategyNames})
+}
+
+// NewEchoOptional is the Echo variant of NewOptional.
+func NewEchoOptional(cfg Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r, user, err := cfg.authenticateOptional(c.Request())
+			if err != nil {
+				cfg.fail(c.Response(), c.Request(), err, JSONErrors)
+				return nil
 			}
 			c.Set(core.ContextUserKey, user)
+			c.SetRequest(r)
 			return next(c)
 		}
 	}
 }
+
+// UserFromEcho retrieves the authenticated User from an Echo context.
+func UserFromEcho(c echo.Context) (core.User, bool) {
+	if user, ok := c.Get(core.ContextUserKey).(core.User); ok {
+		return user, true
+	}
+	return core.UserFromContext(c.Request().Context())
+}
+
+// ResultFromEcho retrieves the AuthResult of the authenticated request from an Echo context.
+func ResultFromEcho(c echo.Context) (*core.AuthResult, bool) {
+	return core.ResultFromContext(c.Request().Context())
+}
@@ -58,3 +58,30 @@ func TestEchoMiddleware_Authorized(t *testing.T) {
 		t.Errorf("expected body 'u1', got '%s'", rec.Body.String())
 	}
 }
+
+func TestEchoMiddleware_PopulatesRequestContext(t *testing.T) {
+	a := core.NewAuthenticator()
+	a.Register(apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(map[string]core.User{"key": dummyUserEcho{"u1"}})}))
+
+	e := echo.New()
+	e.Use(middleware.NewEcho(middleware.Config{Authenticator: a, Strategies: []string{"apikey"}}))
+	e.GET("/", func(c echo.Context) error {
+		fromReq, ok := core.UserFromContext(c.Request().Context())
+		if !ok {
+			t.Fatal("user not found in request context")
+		}
+		fromEcho, ok := middleware.UserFromEcho(c)
+		if !ok || fromEcho.GetID() != fromReq.GetID() {
+			t.Fatalf("UserFromEcho mismatch: %v %v", fromEcho, ok)
+		}
+		return c.String(http.StatusOK, fromReq.GetID())
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", "key")
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "u1" {
+		t.Errorf("expected 200 'u1', got %d '%s'", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-ez-auth/core"
+)
+
+// ErrorHandler writes the response for a request that failed authentication or authorization.
+// err is the detailed error; handlers should choose the status with StatusOf and must not reveal
+// err itself to clients. Retry-After and WWW-Authenticate headers are already set when it runs.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// errorHandlerKey is the context key under which the authentication middleware records its ErrorHandler.
+type errorHandlerKey struct{}
+
+// errorHandlerFrom returns the ErrorHandler recorded in ctx, or def.
+func errorHandlerFrom(ctx context.Context, def ErrorHandler) ErrorHandler {
+	if h, ok := ctx.Value(errorHandlerKey{}).(ErrorHandler); ok {
+		return h
+	}
+	return def
+}
+
+// StatusOf returns the HTTP status for an authentication or authorization error: 429 while locked
+// out (core.ErrThrottled), 403 for core.ErrForbidden, 401 for core.ErrUnauthorized, and 500 for
+// anything else.
+func StatusOf(err error) int {
+	switch {
+	case errors.Is(err, core.ErrThrottled):
+		return http.StatusTooManyRequests
+	case errors.Is(err, core.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, core.ErrUnauthorized):
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// messageOf returns the generic client message for status.
+func messageOf(status int) string {
+	switch status {
+	case http.StatusTooManyRequests:
+		return core.ErrThrottled.Error()
+	case http.StatusForbidden:
+		return core.ErrForbidden.Error()
+	case http.StatusUnauthorized:
+		return core.ErrUnauthorized.Error()
+	}
+	return "internal server error"
+}
+
+// PlainErrors renders failures as a plain-text message.
+func PlainErrors(w http.ResponseWriter, r *http.Request, err error) {
+	status := StatusOf(err)
+	http.Error(w, messageOf(status), status)
+}
+
+// JSONErrors renders failures as {"error": "<message>"}.
+func JSONErrors(w http.ResponseWriter, r *http.Request, err error) {
+	status := StatusOf(err)
+	writeJSON(w, "application/json", status, map[string]string{"error": messageOf(status)})
+}
+
+// Problem is an RFC 7807 problem details object, as written by ProblemErrors.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// ProblemErrors renders failures as application/problem+json.
+func ProblemErrors(w http.ResponseWriter, r *http.Request, err error) {
+	status := StatusOf(err)
+	writeJSON(w, "application/problem+json", status, Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   messageOf(status),
+		Instance: r.URL.Path,
+	})
+}
+
+// writeJSON writes v as JSON with the given content type and status.
+func writeJSON(w http.ResponseWriter, contentType string, status int, v interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// setFailureHeaders sets Retry-After on h while locked out and, for other authentication
+// failures, the strategies' WWW-Authenticate challenges.
+func setFailureHeaders(h http.Header, err error) {
+	var te *core.ThrottledError
+	if errors.As(err, &te) {
+		seconds := int((te.RetryAfter + time.Second - 1) / time.Second)
+		if seconds < 1 {
+			seconds = 1
+		}
+		h.Set("Retry-After", strconv.Itoa(seconds))
+		return
+	}
+	var chainErr *core.ChainError
+	if errors.As(err, &chainErr) {
+		for _, c := range chainErr.Challenges {
+			h.Add("WWW-Authenticate", c)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go-ez-auth/core"
+	"go-ez-auth/middleware"
+)
+
+func TestStatusOf(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{&core.ChainError{Errors: []*core.AuthError{core.NoCredentialsError("jwt")}}, http.StatusUnauthorized},
+		{&core.ChainError{Errors: []*core.AuthError{core.NewAuthError("local", core.ReasonThrottled, &core.ThrottledError{})}}, http.StatusTooManyRequests},
+		{core.ErrForbidden, http.StatusForbidden},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		if got := middleware.StatusOf(tc.err); got != tc.want {
+			t.Errorf("%v: expected %d, got %d", tc.err, tc.want, got)
+		}
+	}
+}
+
+func decodeProblem(t *testing.T, rr *httptest.ResponseRecorder) middleware.Problem {
+	t.Helper()
+	if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("expected problem+json, got %q", ct)
+	}
+	var p middleware.Problem
+	if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestErrorHandler_Problem(t *testing.T) {
+	cfg, az := newAuthzFixture(t)
+	cfg.ErrorHandler = middleware.ProblemErrors
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := middleware.New(cfg)(middleware.RequireRole(az, "admin")(ok))
+
+	// Authentication failure.
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/reports", nil))
+	if p := decodeProblem(t, rr); p.Status != http.StatusUnauthorized || p.Title != "Unauthorized" || p.Instance != "/reports" {
+		t.Errorf("unexpected problem %+v", p)
+	}
+
+	// Authorization failure downstream uses the same handler.
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/reports", nil)
+	req.Header.Set("X-API-Key", "viewer-key")
+	handler.ServeHTTP(rr, req)
+	if p := decodeProblem(t, rr); rr.Code != http.StatusForbidden || p.Status != http.StatusForbidden {
+		t.Errorf("expected 403 problem, got %d %+v", rr.Code, p)
+	}
+}
+
+func TestErrorHandler_Gin(t *testing.T) {
+	cfg, az := newAuthzFixture(t)
+	cfg.ErrorHandler = middleware.ProblemErrors
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(middleware.NewGin(cfg), middleware.GinRequireRole(az, "admin"))
+	e.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", "viewer-key")
+	rr := httptest.NewRecorder()
+	e.ServeHTTP(rr, req)
+	if p := decodeProblem(t, rr); rr.Code != http.StatusForbidden || p.Detail != "forbidden" {
+		t.Errorf("expected 403 problem, got %d %+v", rr.Code, p)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	rr := httptest.NewRecorder()
+	middleware.JSONErrors(rr, httptest.NewRequest("GET", "/", nil), core.ErrUnauthorized)
+	var body map[string]string
+	json.NewDecoder(rr.Body).Decode(&body)
+	if rr.Code != http.StatusUnauthorized || body["error"] != "unauthorized" {
+		t.Errorf("unexpected response %d %v", rr.Code, body)
+	}
+}
@@ -1,21 +1,62 @@
 package middleware
 
 import (
-	"net/http"
-
 	"github.com/gin-gonic/gin"
 	"go-ez-auth/core"
 )
 
-// GinMiddleware returns a gin.HandlerFunc that enforces authentication using given strategies.
+// GinMiddleware returns a gin.HandlerFunc that enforces authentication using given strategies
+// of the default Authenticator.
 func GinMiddleware(strategyNames ...string) gin.HandlerFunc {
+	return NewGin(Config{Strategies: strategyNames})
+}
+
+// NewGin returns a gin.HandlerFunc that enforces authentication as described by cfg.
+func NewGin(cfg Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		user, err := AuthenticateRequest(strategyNames, c.Request)
+		r, res, err := cfg.authenticate(c.Request)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			cfg.fail(c.Writer, c.Request, err, JSONErrors)
+			c.Abort()
+			return
+		}
+		c.Set(core.ContextUserKey, res.User)
+		c.Request = r
+		c.Next()
+	}
+}
+
+// GinOptionalMiddleware is the Gin variant of OptionalMiddleware.
+func GinOptionalMiddleware(strategyNames ...string) gin.HandlerFunc {
+	return NewGinOptional(Config{Strategies: strategyNames})
+}
+
+// NewGinOptional is the Gin variant of NewOptional.
+func NewGinOptional(cfg Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, user, err := cfg.authenticateOptional(c.Request)
+		if err != nil {
+			cfg.fail(c.Writer, c.Request, err, JSONErrors)
+			c.Abort()
 			return
 		}
 		c.Set(core.ContextUserKey, user)
+		c.Request = r
 		c.Next()
 	}
 }
+
+// UserFromGin retrieves the authenticated User from a Gin context.
+func UserFromGin(c *gin.Context) (core.User, bool) {
+	if v, ok := c.Get(core.ContextUserKey); ok {
+		if user, ok := v.(core.User); ok {
+			return user, true
+		}
+	}
+	return core.UserFromContext(c.Request.Context())
+}
+
+// ResultFromGin retrieves the AuthResult of the authenticated request from a Gin context.
+func ResultFromGin(c *gin.Context) (*core.AuthResult, bool) {
+	return core.ResultFromContext(c.Request.Context())
+}
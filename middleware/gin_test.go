@@ -63,3 +63,55 @@ func TestGinMiddleware_Authorized(t *testing.T) {
 		t.Errorf("expected body 'u1', got '%s'", rec.Body.String())
 	}
 }
+
+func TestGinMiddleware_PopulatesRequestContext(t *testing.T) {
+	a := core.NewAuthenticator()
+	a.Register(apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(map[string]core.User{"key": dummyUserGin{"u1"}})}))
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(middleware.NewGin(middleware.Config{Authenticator: a, Strategies: []string{"apikey"}}))
+	e.GET("/", func(c *gin.Context) {
+		fromReq, ok := core.UserFromContext(c.Request.Context())
+		if !ok {
+			t.Fatal("user not found in request context")
+		}
+		fromGin, ok := middleware.UserFromGin(c)
+		if !ok || fromGin.GetID() != fromReq.GetID() {
+			t.Fatalf("UserFromGin mismatch: %v %v", fromGin, ok)
+		}
+		c.String(http.StatusOK, fromReq.GetID())
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", "key")
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "u1" {
+		t.Errorf("expected 200 'u1', got %d '%s'", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGinMiddleware_ResultFromGin(t *testing.T) {
+	a := core.NewAuthenticator()
+	a.Register(apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(map[string]core.User{"key": dummyUserGin{"u1"}})}))
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(middleware.NewGin(middleware.Config{Authenticator: a, Strategies: []string{"apikey"}}))
+	e.GET("/", func(c *gin.Context) {
+		res, ok := middleware.ResultFromGin(c)
+		if !ok {
+			t.Fatal("result not found")
+		}
+		c.String(http.StatusOK, res.Strategy)
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", "key")
+	e.ServeHTTP(rec, req)
+	if rec.Body.String() != "apikey" {
+		t.Errorf("expected strategy 'apikey', got '%s'", rec.Body.String())
+	}
+}
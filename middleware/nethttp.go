@@ -1,38 +1,58 @@
 package middleware
 
 import (
-	"context"
 	"net/http"
 
 	"go-ez-auth/core"
 )
 
-// AuthenticateRequest tries each named strategy in order and returns the first successful user.
+// AuthenticateRequest tries each named strategy of the default Authenticator in order
+// and returns the first successful user.
 func AuthenticateRequest(strategyNames []string, r *http.Request) (core.User, error) {
-	for _, name := range strategyNames {
-		strat, ok := core.GetStrategy(name)
-		if !ok {
-			continue
-		}
-		user, err := strat.Authenticate(r.Context(), r)
-		if err == nil {
-			return user, nil
-		}
+	_, res, err := Config{Strategies: strategyNames}.authenticate(r)
+	if err != nil {
+		return nil, err
 	}
-	return nil, core.ErrUnauthorized
+	return res.User, nil
 }
 
-// Middleware returns a net/http middleware that enforces authentication.
+// Middleware returns a net/http middleware that enforces authentication using the default Authenticator.
 func Middleware(strategyNames ...string) func(http.Handler) http.Handler {
+	return New(Config{Strategies: strategyNames})
+}
+
+// New returns a net/http middleware that enforces authentication as described by cfg.
+func New(cfg Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, _, err := cfg.authenticate(r)
+			if err != nil {
+				cfg.fail(w, r, err, PlainErrors)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// OptionalMiddleware returns a net/http middleware that authenticates using the default Authenticator
+// when credentials are present and lets anonymous requests through.
+func OptionalMiddleware(strategyNames ...string) func(http.Handler) http.Handler {
+	return NewOptional(Config{Strategies: strategyNames})
+}
+
+// NewOptional returns a net/http middleware for endpoints serving both anonymous and authenticated
+// users. Requests with valid credentials carry the user as with New; requests without credentials
+// carry core.Anonymous; requests presenting invalid credentials are rejected.
+func NewOptional(cfg Config) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			user, err := AuthenticateRequest(strategyNames, r)
+			r, _, err := cfg.authenticateOptional(r)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusUnauthorized)
+				cfg.fail(w, r, err, PlainErrors)
 				return
 			}
-			ctx := context.WithValue(r.Context(), core.ContextUserKey, user)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r)
 		})
 	}
 }
@@ -3,12 +3,17 @@ package middleware_test
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"go-ez-auth/core"
+	"go-ez-auth/core/throttle"
 	"go-ez-auth/middleware"
 	"go-ez-auth/stores"
 	"go-ez-auth/strategies/apikey"
+	"go-ez-auth/strategies/jwt"
+	"go-ez-auth/strategies/local"
 )
 
 type dummyUserNet struct{ id string }
@@ -56,3 +61,144 @@ func TestMiddleware_Authorized(t *testing.T) {
 		t.Errorf("expected body 'u1', got '%s'", rr.Body.String())
 	}
 }
+
+func TestNew_UsesOwnAuthenticator(t *testing.T) {
+	a := core.NewAuthenticator()
+	store := stores.NewAPIKeyStore(map[string]core.User{"own": dummyUserNet{"u2"}})
+	a.Register(apikey.New(apikey.Config{Store: store}))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := core.UserFromContext(r.Context())
+		w.Write([]byte(user.GetID()))
+	})
+	mw := middleware.New(middleware.Config{Authenticator: a, Strategies: []string{"apikey"}})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", "own")
+	mw(handler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "u2" {
+		t.Fatalf("expected 200 'u2', got %d '%s'", rr.Code, rr.Body.String())
+	}
+
+	// The key is unknown to the default Authenticator's strategy.
+	rr = httptest.NewRecorder()
+	middleware.Middleware("apikey")(handler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 from default authenticator, got %d", rr.Code)
+	}
+}
+
+func TestNew_OnErrorReceivesDetails(t *testing.T) {
+	a := core.NewAuthenticator()
+	a.Register(apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(nil)}))
+
+	var got error
+	mw := middleware.New(middleware.Config{
+		Authenticator: a,
+		Strategies:    []string{"apikey"},
+		OnError:       func(r *http.Request, err error) { got = err },
+	})
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", "bogus")
+	mw(http.NotFoundHandler()).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rr.Code)
+	}
+	if body := strings.TrimSpace(rr.Body.String()); body != "unauthorized" {
+		t.Errorf("expected generic body, got %q", body)
+	}
+	if core.ReasonOf(got) != core.ReasonUnknownKey {
+		t.Errorf("expected unknown_key reason, got %v", got)
+	}
+}
+
+func TestNew_ChainMode(t *testing.T) {
+	a := core.NewAuthenticator()
+	a.Register(jwt.New(jwt.Config{SigningKey: []byte("secret")}))
+	a.Register(apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(map[string]core.User{"key": dummyUserNet{"u1"}})}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer expired.or.garbage")
+	req.Header.Set("X-API-Key", "key")
+
+	cases := []struct {
+		mode core.ChainMode
+		want int
+	}{
+		{core.StopOnInvalid, http.StatusUnauthorized},
+		{core.ContinueOnInvalid, http.StatusOK},
+	}
+	for _, tc := range cases {
+		mw := middleware.New(middleware.Config{Authenticator: a, Strategies: []string{"jwt", "apikey"}, ChainMode: tc.mode})
+		rr := httptest.NewRecorder()
+		mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Errorf("mode %d: expected %d, got %d", tc.mode, tc.want, rr.Code)
+		}
+	}
+}
+
+func TestNew_StoresAuthResult(t *testing.T) {
+	a := core.NewAuthenticator()
+	a.Register(apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(map[string]core.User{"key": dummyUserNet{"u1"}})}))
+
+	var res *core.AuthResult
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, _ = core.ResultFromContext(r.Context())
+	})
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", "key")
+	middleware.New(middleware.Config{Authenticator: a, Strategies: []string{"apikey"}})(handler).ServeHTTP(rr, req)
+
+	if res == nil {
+		t.Fatal("expected AuthResult in request context")
+	}
+	if res.Strategy != "apikey" || res.User.GetID() != "u1" || res.CredentialID != apikey.Fingerprint("key") {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestNew_Throttled(t *testing.T) {
+	auth := core.NewAuthenticator()
+	limiter := throttle.New(throttle.Config{MaxFailures: 1, Lockout: 90 * time.Second})
+	auth.Register(apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(nil), Limiter: limiter}))
+	mw := middleware.New(middleware.Config{Authenticator: auth, Strategies: []string{"apikey"}})
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	codes := make([]int, 2)
+	for i := range codes {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-API-Key", "guess")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		codes[i] = rr.Code
+		if i == 1 && rr.Header().Get("Retry-After") != "90" {
+			t.Errorf("expected Retry-After 90, got %q", rr.Header().Get("Retry-After"))
+		}
+	}
+	if codes[0] != http.StatusUnauthorized || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("expected 401 then 429, got %v", codes)
+	}
+}
+
+func TestNew_WWWAuthenticate(t *testing.T) {
+	auth := core.NewAuthenticator()
+	auth.Register(jwt.New(jwt.Config{SigningKey: []byte("secret")}))
+	auth.Register(local.New(local.Config{UserStore: stores.NewInMemoryUserStore(), Realm: "intranet"}))
+	mw := middleware.New(middleware.Config{Authenticator: auth, Strategies: []string{"jwt", "local"}})
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer invalid.token.here")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	got := rr.Header().Values("WWW-Authenticate")
+	want := []string{`Bearer error="invalid_token"`, `Basic realm="intranet", charset="UTF-8"`}
+	if rr.Code != http.StatusUnauthorized || strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expected 401 with %q, got %d %q", want, rr.Code, got)
+	}
+}
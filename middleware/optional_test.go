@@ -0,0 +1,92 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+	"go-ez-auth/core"
+	"go-ez-auth/core/authz"
+	"go-ez-auth/middleware"
+	"go-ez-auth/stores"
+	"go-ez-auth/strategies/apikey"
+)
+
+func newOptionalConfig() middleware.Config {
+	auth := core.NewAuthenticator()
+	auth.Register(apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(map[string]core.User{"key": &stores.User{ID: "u1"}})}))
+	return middleware.Config{Authenticator: auth, Strategies: []string{"apikey"}}
+}
+
+// optionalCases lists the API key sent, the expected status, and the expected user ID.
+var optionalCases = []struct {
+	key  string
+	code int
+	user string
+}{
+	{"key", http.StatusOK, "u1"},
+	{"", http.StatusOK, core.AnonymousID},
+	{"wrong", http.StatusUnauthorized, ""},
+}
+
+func runOptionalCases(t *testing.T, name string, h http.Handler) {
+	t.Helper()
+	for _, tt := range optionalCases {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.key != "" {
+			req.Header.Set("X-API-Key", tt.key)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tt.code {
+			t.Errorf("%s with key %q: expected %d, got %d", name, tt.key, tt.code, rr.Code)
+		}
+		if tt.code == http.StatusOK && rr.Body.String() != tt.user {
+			t.Errorf("%s with key %q: expected user %q, got %q", name, tt.key, tt.user, rr.Body.String())
+		}
+	}
+}
+
+func TestNewOptional(t *testing.T) {
+	h := middleware.NewOptional(newOptionalConfig())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := core.UserFromContext(r.Context())
+		if _, ok := core.ResultFromContext(r.Context()); ok == core.IsAnonymous(user) {
+			t.Errorf("expected a result only for authenticated users")
+		}
+		w.Write([]byte(user.GetID()))
+	}))
+	runOptionalCases(t, "net/http", h)
+}
+
+func TestNewGinOptional(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(middleware.NewGinOptional(newOptionalConfig()))
+	e.GET("/", func(c *gin.Context) {
+		user, _ := middleware.UserFromGin(c)
+		c.String(http.StatusOK, user.GetID())
+	})
+	runOptionalCases(t, "gin", e)
+}
+
+func TestNewEchoOptional(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.NewEchoOptional(newOptionalConfig()))
+	e.GET("/", func(c echo.Context) error {
+		user, _ := middleware.UserFromEcho(c)
+		return c.String(http.StatusOK, user.GetID())
+	})
+	runOptionalCases(t, "echo", e)
+}
+
+func TestRequireRole_AnonymousIsUnauthorized(t *testing.T) {
+	h := middleware.NewOptional(newOptionalConfig())(
+		middleware.RequireRole(authz.New(authz.Config{}), "admin")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for anonymous user, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+	"go-ez-auth/core"
+	"go-ez-auth/core/policy"
+)
+
+// policyCheck builds a check that evaluates engine for r with the given resource loader.
+func policyCheck(engine *policy.Engine, r *http.Request, resource func() (map[string]interface{}, error)) authzCheck {
+	return func(ctx context.Context, user core.User) (bool, error) {
+		var attrs map[string]interface{}
+		if resource != nil {
+			var err error
+			if attrs, err = resource(); err != nil {
+				return false, err
+			}
+		}
+		d, err := engine.Evaluate(ctx, policy.Input{User: user, Request: r, Resource: attrs})
+		return d.Allowed, err
+	}
+}
+
+// RequirePolicy returns a net/http middleware that allows a request only if engine's policy allows it.
+// resource, which may be nil, supplies the attributes of the resource being accessed.
+// It must run after an authentication middleware.
+func RequirePolicy(engine *policy.Engine, resource func(r *http.Request) (map[string]interface{}, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var load func() (map[string]interface{}, error)
+			if resource != nil {
+				load = func() (map[string]interface{}, error) { return resource(r) }
+			}
+			requireNetHTTP(policyCheck(engine, r, load))(next).ServeHTTP(w, r)
+		})
+	}
+}
+
+// GinRequirePolicy is the Gin variant of RequirePolicy.
+func GinRequirePolicy(engine *policy.Engine, resource func(c *gin.Context) (map[string]interface{}, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var load func() (map[string]interface{}, error)
+		if resource != nil {
+			load = func() (map[string]interface{}, error) { return resource(c) }
+		}
+		requireGin(policyCheck(engine, c.Request, load))(c)
+	}
+}
+
+// EchoRequirePolicy is the Echo variant of RequirePolicy.
+func EchoRequirePolicy(engine *policy.Engine, resource func(c echo.Context) (map[string]interface{}, error)) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var load func() (map[string]interface{}, error)
+			if resource != nil {
+				load = func() (map[string]interface{}, error) { return resource(c) }
+			}
+			return requireEcho(policyCheck(engine, c.Request(), load))(next)(c)
+		}
+	}
+}
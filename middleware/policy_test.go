@@ -0,0 +1,91 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+	"go-ez-auth/core"
+	"go-ez-auth/core/policy"
+	"go-ez-auth/middleware"
+	"go-ez-auth/stores"
+	"go-ez-auth/strategies/apikey"
+)
+
+type deptUser struct{ id, dept string }
+
+func (u deptUser) GetID() string { return u.id }
+func (u deptUser) GetAttributes() map[string]interface{} {
+	return map[string]interface{}{"department": u.dept}
+}
+
+func newPolicyFixture(t *testing.T) (middleware.Config, *policy.Engine) {
+	a := core.NewAuthenticator()
+	a.Register(apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(map[string]core.User{
+		"eng-key":   deptUser{"u1", "eng"},
+		"sales-key": deptUser{"u2", "sales"},
+	})}))
+	p, err := policy.LoadJSON(strings.NewReader(`{"rules": [
+		{"name": "dept", "effect": "allow", "condition": "user.department == resource.owner_dept && request.method in [\"GET\"]"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := policy.New(policy.Config{Policy: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return middleware.Config{Authenticator: a, Strategies: []string{"apikey"}}, engine
+}
+
+func TestRequirePolicy_NetHTTP(t *testing.T) {
+	cfg, engine := newPolicyFixture(t)
+	resource := func(r *http.Request) (map[string]interface{}, error) {
+		return map[string]interface{}{"owner_dept": "eng"}, nil
+	}
+	handler := middleware.New(cfg)(middleware.RequirePolicy(engine, resource)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	cases := map[string]int{"eng-key": http.StatusOK, "sales-key": http.StatusForbidden}
+	for key, want := range cases {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-API-Key", key)
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", key, want, rr.Code)
+		}
+	}
+}
+
+func TestRequirePolicy_GinAndEcho(t *testing.T) {
+	cfg, engine := newPolicyFixture(t)
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(middleware.NewGin(cfg), middleware.GinRequirePolicy(engine, func(c *gin.Context) (map[string]interface{}, error) {
+		return map[string]interface{}{"owner_dept": c.Param("dept")}, nil
+	}))
+	g.GET("/:dept", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	e := echo.New()
+	e.Use(middleware.NewEcho(cfg))
+	e.GET("/:dept", func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+		middleware.EchoRequirePolicy(engine, func(c echo.Context) (map[string]interface{}, error) {
+			return map[string]interface{}{"owner_dept": c.Param("dept")}, nil
+		}))
+
+	for name, h := range map[string]http.Handler{"gin": g, "echo": e} {
+		for path, want := range map[string]int{"/eng": http.StatusOK, "/sales": http.StatusForbidden} {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", path, nil)
+			req.Header.Set("X-API-Key", "eng-key")
+			h.ServeHTTP(rec, req)
+			if rec.Code != want {
+				t.Errorf("%s %s: expected %d, got %d", name, path, want, rec.Code)
+			}
+		}
+	}
+}
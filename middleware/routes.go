@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"go-ez-auth/core"
+)
+
+// Route describes how requests matching Pattern are authenticated and authorized.
+type Route struct {
+	// Pattern is an http.ServeMux pattern such as "GET /api/items/{id}", "/admin/" or
+	// "api.example.com/". Requests are matched with the same precedence rules as ServeMux.
+	Pattern    string
+	Strategies []string       // strategies tried for matching requests, replacing Config.Strategies if not empty
+	ChainMode  core.ChainMode // replaces Config.ChainMode for matching requests, along with Strategies
+
+	// Public skips authentication. Optional lets requests without credentials through as
+	// core.Anonymous, like NewOptional.
+	Public   bool
+	Optional bool
+
+	// Require, if set, runs after authentication, e.g. RequireRole(az, "admin").
+	Require func(http.Handler) http.Handler
+}
+
+// NewRouter returns a net/http middleware that protects a whole mux with a route table: each
+// request is authenticated with only the strategies of the route whose Pattern matches it.
+// Requests matching no route are authenticated with cfg's Strategies; add a "/" route to change
+// that. Requests that ServeMux would redirect, such as "/admin/../public/x" or "/admin" for an
+// "/admin/" route, are redirected the same way rather than authenticated for the route of the
+// redirect's target. It returns an error if a pattern is invalid or conflicts with another.
+func NewRouter(cfg Config, routes []Route) (func(http.Handler) http.Handler, error) {
+	mux := http.NewServeMux()
+	for i, route := range routes {
+		if route.Public && (route.Optional || route.Require != nil) {
+			return nil, fmt.Errorf("middleware: route %q: Public cannot be combined with Optional or Require", route.Pattern)
+		}
+		if err := registerPattern(mux, route.Pattern, routeIndex(i)); err != nil {
+			return nil, err
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		handlers := make([]http.Handler, len(routes))
+		for i, route := range routes {
+			handlers[i] = route.handler(cfg, next)
+		}
+		fallback := New(cfg)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h, pattern := mux.Handler(r)
+			if i, ok := h.(routeIndex); ok {
+				handlers[i].ServeHTTP(w, r)
+				return
+			}
+			if pattern != "" {
+				// Every registered handler is a routeIndex, so this is ServeMux's redirect to
+				// the canonical path, which is authenticated when the client follows it.
+				h.ServeHTTP(w, r)
+				return
+			}
+			fallback.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// routeIndex is registered with the router's mux for each route, identifying the route a
+// request matches.
+type routeIndex int
+
+func (routeIndex) ServeHTTP(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) }
+
+// registerPattern adds pattern to mux, converting ServeMux's panics into errors.
+func registerPattern(mux *http.ServeMux, pattern string, h http.Handler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("middleware: route %q: %v", pattern, p)
+		}
+	}()
+	mux.Handle(pattern, h)
+	return nil
+}
+
+// handler wraps next with the route's authentication and authorization.
+func (route Route) handler(cfg Config, next http.Handler) http.Handler {
+	if route.Public {
+		return next
+	}
+	if route.Require != nil {
+		next = route.Require(next)
+	}
+	if len(route.Strategies) > 0 {
+		cfg.Strategies, cfg.ChainMode = route.Strategies, route.ChainMode
+	}
+	if route.Optional {
+		return NewOptional(cfg)(next)
+	}
+	return New(cfg)(next)
+}
@@ -0,0 +1,159 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-ez-auth/core"
+	"go-ez-auth/middleware"
+	"go-ez-auth/stores"
+	"go-ez-auth/strategies/apikey"
+	"go-ez-auth/strategies/jwt"
+)
+
+// countingStrategy records whether it ran.
+type countingStrategy struct {
+	core.Strategy
+	runs *int
+}
+
+func (c countingStrategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	*c.runs++
+	return c.Strategy.Authenticate(ctx, r)
+}
+
+func TestNewRouter(t *testing.T) {
+	cfg, az := newAuthzFixture(t)
+	var jwtRuns int
+	cfg.Authenticator.Register(countingStrategy{jwt.New(jwt.Config{SigningKey: []byte("secret")}), &jwtRuns})
+	cfg.Strategies = nil
+
+	router, err := middleware.NewRouter(cfg, []middleware.Route{
+		{Pattern: "/", Public: true},
+		{Pattern: "/api/", Strategies: []string{"apikey", "jwt"}},
+		{Pattern: "GET /reports/{id}", Strategies: []string{"apikey"}, Optional: true},
+		{Pattern: "/admin/", Strategies: []string{"apikey"}, Require: middleware.RequireRole(az, "admin")},
+	})
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if u, ok := core.UserFromContext(r.Context()); ok {
+			w.Write([]byte(u.GetID()))
+		}
+	})
+	handler := router(mux)
+
+	cases := []struct {
+		method, path, key string
+		code              int
+		body              string
+	}{
+		{"GET", "/", "", http.StatusOK, ""},
+		{"GET", "/api/items", "", http.StatusUnauthorized, ""},
+		{"GET", "/api/items", "viewer-key", http.StatusOK, "viewer"},
+		{"GET", "/reports/7", "", http.StatusOK, core.AnonymousID},
+		{"GET", "/reports/7", "viewer-key", http.StatusOK, "viewer"},
+		{"POST", "/reports/7", "", http.StatusOK, ""}, // method not covered: falls back to the "/" route
+		{"GET", "/admin/users", "viewer-key", http.StatusForbidden, ""},
+		{"GET", "/admin/users", "admin-key", http.StatusOK, "admin"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.key != "" {
+			req.Header.Set("X-API-Key", tc.key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.code || (tc.code == http.StatusOK && rr.Body.String() != tc.body) {
+			t.Errorf("%s %s (%q): expected %d %q, got %d %q", tc.method, tc.path, tc.key, tc.code, tc.body, rr.Code, rr.Body.String())
+		}
+	}
+	// Only the /api/ route lists jwt, and there the key succeeded first.
+	if jwtRuns != 1 {
+		t.Errorf("expected jwt to run only for the unauthenticated /api/ request, ran %d times", jwtRuns)
+	}
+}
+
+func TestNewRouter_Fallback(t *testing.T) {
+	auth := core.NewAuthenticator()
+	auth.Register(apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(nil)}))
+	router, err := middleware.NewRouter(middleware.Config{Authenticator: auth, Strategies: []string{"apikey"}},
+		[]middleware.Route{{Pattern: "/health", Public: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := router(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for path, want := range map[string]int{"/health": http.StatusOK, "/other": http.StatusUnauthorized} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, rr.Code)
+		}
+	}
+}
+
+func TestNewRouter_RequireOnly(t *testing.T) {
+	cfg, az := newAuthzFixture(t)
+	router, err := middleware.NewRouter(cfg, []middleware.Route{{Pattern: "/admin/", Require: middleware.RequireRole(az, "admin")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := router(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// The route lists no strategies, so requests are authenticated with cfg's.
+	for key, want := range map[string]int{"": http.StatusUnauthorized, "viewer-key": http.StatusForbidden, "admin-key": http.StatusOK} {
+		req := httptest.NewRequest("GET", "/admin/users", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("%q: expected %d, got %d", key, want, rr.Code)
+		}
+	}
+}
+
+func TestNewRouter_InvalidPatterns(t *testing.T) {
+	for name, routes := range map[string][]middleware.Route{
+		"malformed":   {{Pattern: "GET"}},
+		"conflict":    {{Pattern: "/a/{x}"}, {Pattern: "/a/{y}"}},
+		"public+auth": {{Pattern: "/", Public: true, Optional: true}},
+	} {
+		if _, err := middleware.NewRouter(middleware.Config{}, routes); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestNewRouter_NonCanonicalPaths(t *testing.T) {
+	cfg, _ := newAuthzFixture(t)
+	router, err := middleware.NewRouter(cfg, []middleware.Route{
+		{Pattern: "/public/", Public: true},
+		{Pattern: "/admin/", Strategies: []string{"apikey"}},
+	})
+	if err != nil {
+		t.Fatalf("NewRouter: %v", err)
+	}
+	var served []string
+	handler := router(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = append(served, r.URL.Path)
+	}))
+	for path, want := range map[string]string{
+		"/admin/../public/x": "/public/x",
+		"/public//x":         "/public/x",
+		"/admin":             "/admin/",
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusTemporaryRedirect || rr.Header().Get("Location") != want {
+			t.Errorf("%s: expected redirect to %s, got %d %q", path, want, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+	if len(served) != 0 {
+		t.Errorf("expected no request to reach the handler, got %v", served)
+	}
+}
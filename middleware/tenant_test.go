@@ -0,0 +1,67 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-ez-auth/core"
+	"go-ez-auth/core/tenant"
+	"go-ez-auth/middleware"
+	"go-ez-auth/strategies/jwt"
+)
+
+func TestNew_PerTenantStrategies(t *testing.T) {
+	newTenant := func(id, key string) (*tenant.Tenant, *jwt.Strategy) {
+		strat := jwt.New(jwt.Config{SigningKey: []byte(key), Issuer: id})
+		a := core.NewAuthenticator()
+		if err := a.Register(strat); err != nil {
+			t.Fatal(err)
+		}
+		return &tenant.Tenant{ID: id, Authenticator: a}, strat
+	}
+	acme, acmeJWT := newTenant("acme", "acme-secret")
+	globex, _ := newTenant("globex", "globex-secret")
+	m, err := tenant.New(tenant.Config{Resolver: tenant.HostResolver{BaseDomain: "example.com"}, Tenants: []*tenant.Tenant{acme, globex}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotTenant string
+	handler := middleware.New(middleware.Config{Tenants: m, Strategies: []string{"jwt"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = tenant.IDFromContext(r.Context())
+	}))
+	token, err := acmeJWT.Issue(nil, dummyUserNet{"u1"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		host string
+		want int
+	}{
+		{"acme.example.com", http.StatusOK},
+		{"globex.example.com", http.StatusUnauthorized}, // acme's token is not valid for globex
+		{"unknown.example.com", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		gotTenant = ""
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = tc.host
+		req.Header.Set("Authorization", "Bearer "+token)
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.host, tc.want, rr.Code)
+		}
+	}
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "acme.example.com"
+	req.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(rr, req)
+	if gotTenant != "acme" {
+		t.Errorf("expected tenant acme in context, got %q", gotTenant)
+	}
+}
@@ -2,27 +2,168 @@ package stores
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 
 	"go-ez-auth/core"
 )
 
-// APIKeyStore maps API keys to users.
+// DefaultAPIKeyPrefix is the prefix of keys issued by an APIKeyStore unless configured otherwise.
+const DefaultAPIKeyPrefix = "ezk"
+
+// ErrMalformedAPIKey is returned when a key is not in the store's prefix_id_secret format.
+var ErrMalformedAPIKey = errors.New("stores: malformed api key")
+
+// keyEncoding encodes key IDs and secrets; its alphabet has no "_", so keys split unambiguously.
+var keyEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// APIKeyStoreConfig holds settings for an APIKeyStore.
+type APIKeyStoreConfig struct {
+	Prefix string // prefix of issued keys; default DefaultAPIKeyPrefix
+	Pepper []byte // optional HMAC-SHA256 key for digests, kept apart from stored digests; plain SHA-256 if empty
+}
+
+// APIKeyStore maps API keys to users. Keys are issued as "prefix_id_secret": the ID is public
+// and used for lookup, and only a SHA-256 (or HMAC-SHA256) digest of the secret is kept and
+// compared in constant time. Keys in any other format are kept as a digest of the whole key.
+// No plaintext key is retained. It is safe for concurrent use.
 type APIKeyStore struct {
-	byKey map[string]core.User
+	// Events, if set, receives token issued and key revoked events.
+	Events core.EventEmitter
+
+	prefix string
+	pepper []byte
+
+	mu       sync.RWMutex
+	byID     map[string]apiKeyEntry // public key ID -> secret digest and user
+	byDigest map[string]core.User   // digest of a whole unstructured key -> user
 }
 
-// NewAPIKeyStore creates a store with the given key->User mapping.
+// apiKeyEntry is a stored prefixed key.
+type apiKeyEntry struct {
+	digest []byte
+	user   core.User
+}
+
+// NewAPIKeyStore creates a store with the given key->User mapping and the default config.
 func NewAPIKeyStore(mapping map[string]core.User) *APIKeyStore {
-	m := make(map[string]core.User)
+	return NewAPIKeyStoreWithConfig(APIKeyStoreConfig{}, mapping)
+}
+
+// NewAPIKeyStoreWithConfig creates a store from config with the given key->User mapping. The
+// mapping's keys are hashed on the way in and the map is not retained.
+func NewAPIKeyStoreWithConfig(config APIKeyStoreConfig, mapping map[string]core.User) *APIKeyStore {
+	if config.Prefix == "" {
+		config.Prefix = DefaultAPIKeyPrefix
+	}
+	s := &APIKeyStore{
+		prefix:   config.Prefix,
+		pepper:   config.Pepper,
+		byID:     make(map[string]apiKeyEntry),
+		byDigest: make(map[string]core.User),
+	}
 	for k, u := range mapping {
-		m[k] = u
+		s.Add(k, u)
+	}
+	return s
+}
+
+// digest hashes a secret with the store's pepper, if any.
+func (s *APIKeyStore) digest(secret string) []byte {
+	if len(s.pepper) == 0 {
+		sum := sha256.Sum256([]byte(secret))
+		return sum[:]
+	}
+	mac := hmac.New(sha256.New, s.pepper)
+	mac.Write([]byte(secret))
+	return mac.Sum(nil)
+}
+
+// parse splits a key in the store's format into its ID and secret.
+func (s *APIKeyStore) parse(key string) (id, secret string, ok bool) {
+	rest, found := strings.CutPrefix(key, s.prefix+"_")
+	if !found {
+		return "", "", false
+	}
+	id, secret, found = strings.Cut(rest, "_")
+	if !found || id == "" || secret == "" || strings.Contains(secret, "_") {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// Add stores an existing key for u: by its public ID if it is in the store's format, or as a
+// digest of the whole key otherwise.
+func (s *APIKeyStore) Add(key string, u core.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, secret, ok := s.parse(key); ok {
+		s.byID[id] = apiKeyEntry{digest: s.digest(secret), user: u}
+		return
+	}
+	s.byDigest[string(s.digest(key))] = u
+}
+
+// Issue generates a new random key for user, stores its digest, and returns the key. The key
+// cannot be recovered from the store afterwards.
+func (s *APIKeyStore) Issue(ctx context.Context, user core.User) (string, error) {
+	raw := make([]byte, 10+32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("stores: generate api key: %w", err)
 	}
-	return &APIKeyStore{byKey: m}
+	id, secret := keyEncoding.EncodeToString(raw[:10]), keyEncoding.EncodeToString(raw[10:])
+	s.mu.Lock()
+	s.byID[id] = apiKeyEntry{digest: s.digest(secret), user: user}
+	s.mu.Unlock()
+	if s.Events != nil {
+		e := core.NewEvent(core.EventTokenIssued, nil)
+		e.Strategy, e.UserID = "apikey", user.GetID()
+		s.Events.Emit(ctx, e)
+	}
+	return s.prefix + "_" + id + "_" + secret, nil
+}
+
+// Digest returns the public ID and hex digest of a key in the store's format, for persisting
+// keys elsewhere and restoring them with AddDigest.
+func (s *APIKeyStore) Digest(key string) (id, digest string, err error) {
+	id, secret, ok := s.parse(key)
+	if !ok {
+		return "", "", ErrMalformedAPIKey
+	}
+	return id, hex.EncodeToString(s.digest(secret)), nil
+}
+
+// AddDigest stores a key for user by its public ID and hex digest, as returned by Digest, so a
+// key can be loaded without its plaintext.
+func (s *APIKeyStore) AddDigest(id, digest string, user core.User) error {
+	d, err := hex.DecodeString(digest)
+	if err != nil || len(d) != sha256.Size || id == "" {
+		return ErrMalformedAPIKey
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byID[id] = apiKeyEntry{digest: d, user: user}
+	return nil
 }
 
-// FindUserByID looks up a user by user.ID across all mapped values.
+// FindUserByID looks up a user by user.ID across all stored keys.
 func (s *APIKeyStore) FindUserByID(ctx context.Context, id string) (core.User, error) {
-	for _, u := range s.byKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, e := range s.byID {
+		if e.user.GetID() == id {
+			return e.user, nil
+		}
+	}
+	for _, u := range s.byDigest {
 		if u.GetID() == id {
 			return u, nil
 		}
@@ -37,9 +178,73 @@ func (s *APIKeyStore) FindUserByCredentials(ctx context.Context, criteria map[st
 		if !ok {
 			continue
 		}
-		if u, exists := s.byKey[key]; exists {
+		if u, ok := s.lookup(key); ok {
 			return u, nil
 		}
 	}
 	return nil, core.ErrInvalidCredentials
 }
+
+// lookup verifies key and returns its user.
+func (s *APIKeyStore) lookup(key string) (core.User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if id, secret, ok := s.parse(key); ok {
+		e, found := s.byID[id]
+		if found && subtle.ConstantTimeCompare(e.digest, s.digest(secret)) == 1 {
+			return e.user, true
+		}
+		return nil, false
+	}
+	u, ok := s.byDigest[string(s.digest(key))]
+	return u, ok
+}
+
+// Revoke removes key from the store so it can no longer authenticate.
+func (s *APIKeyStore) Revoke(ctx context.Context, key string) error {
+	s.mu.Lock()
+	u, ok := s.remove(key)
+	s.mu.Unlock()
+	if !ok {
+		return core.ErrInvalidCredentials
+	}
+	s.emitRevoked(ctx, u)
+	return nil
+}
+
+// remove deletes key after verifying it. s.mu must be held.
+func (s *APIKeyStore) remove(key string) (core.User, bool) {
+	if id, secret, ok := s.parse(key); ok {
+		e, found := s.byID[id]
+		if !found || subtle.ConstantTimeCompare(e.digest, s.digest(secret)) != 1 {
+			return nil, false
+		}
+		delete(s.byID, id)
+		return e.user, true
+	}
+	d := string(s.digest(key))
+	u, ok := s.byDigest[d]
+	delete(s.byDigest, d)
+	return u, ok
+}
+
+// RevokeID removes the key with the given public ID, so keys can be revoked without their secret.
+func (s *APIKeyStore) RevokeID(ctx context.Context, id string) error {
+	s.mu.Lock()
+	e, ok := s.byID[id]
+	delete(s.byID, id)
+	s.mu.Unlock()
+	if !ok {
+		return core.ErrInvalidCredentials
+	}
+	s.emitRevoked(ctx, e.user)
+	return nil
+}
+
+func (s *APIKeyStore) emitRevoked(ctx context.Context, u core.User) {
+	if s.Events != nil {
+		e := core.NewEvent(core.EventKeyRevoked, nil)
+		e.Strategy, e.UserID = "apikey", u.GetID()
+		s.Events.Emit(ctx, e)
+	}
+}
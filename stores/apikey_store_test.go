@@ -0,0 +1,84 @@
+package stores_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-ez-auth/core"
+	"go-ez-auth/stores"
+)
+
+func TestAPIKeyStore_Revoke(t *testing.T) {
+	s := stores.NewAPIKeyStore(map[string]core.User{"key": dummyUser{"u1"}})
+	events := core.NewAuthenticator()
+	var got []core.Event
+	events.Subscribe(func(ctx context.Context, e core.Event) { got = append(got, e) })
+	s.Events = events
+
+	ctx := context.Background()
+	if err := s.Revoke(ctx, "key"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"id": "key"}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected revoked key to be rejected, got %v", err)
+	}
+	if err := s.Revoke(ctx, "key"); err != core.ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials for unknown key, got %v", err)
+	}
+	if len(got) != 1 || got[0].Type != core.EventKeyRevoked || got[0].UserID != "u1" {
+		t.Errorf("expected one key revoked event, got %+v", got)
+	}
+}
+
+func TestAPIKeyStore_Issue(t *testing.T) {
+	s := stores.NewAPIKeyStoreWithConfig(stores.APIKeyStoreConfig{Prefix: "sk_live", Pepper: []byte("pepper")}, nil)
+	ctx := context.Background()
+	key, err := s.Issue(ctx, dummyUser{"u1"})
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if !strings.HasPrefix(key, "sk_live_") {
+		t.Errorf("expected the configured prefix, got %q", key)
+	}
+	if u, err := s.FindUserByCredentials(ctx, map[string]interface{}{"id": key}); err != nil || u.GetID() != "u1" {
+		t.Errorf("expected issued key to authenticate, got %v %v", u, err)
+	}
+	tampered := key[:len(key)-1] + "a"
+	if tampered == key {
+		tampered = key[:len(key)-1] + "b"
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"id": tampered}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected a wrong secret to be rejected, got %v", err)
+	}
+
+	// A key restored from its digest authenticates without the plaintext ever being stored.
+	id, digest, err := s.Digest(key)
+	if err != nil || strings.Contains(digest, key[strings.LastIndex(key, "_")+1:]) {
+		t.Fatalf("Digest: %q %v", digest, err)
+	}
+	restored := stores.NewAPIKeyStoreWithConfig(stores.APIKeyStoreConfig{Prefix: "sk_live", Pepper: []byte("pepper")}, nil)
+	if err := restored.AddDigest(id, digest, dummyUser{"u1"}); err != nil {
+		t.Fatalf("AddDigest: %v", err)
+	}
+	if _, err := restored.FindUserByCredentials(ctx, map[string]interface{}{"id": key}); err != nil {
+		t.Errorf("expected restored key to authenticate, got %v", err)
+	}
+	if err := restored.AddDigest(id, "not-hex", dummyUser{"u1"}); !errors.Is(err, stores.ErrMalformedAPIKey) {
+		t.Errorf("expected ErrMalformedAPIKey, got %v", err)
+	}
+	if _, _, err := s.Digest("legacy-key"); !errors.Is(err, stores.ErrMalformedAPIKey) {
+		t.Errorf("expected ErrMalformedAPIKey for an unprefixed key, got %v", err)
+	}
+
+	if err := restored.RevokeID(ctx, id); err != nil {
+		t.Fatalf("RevokeID: %v", err)
+	}
+	if _, err := restored.FindUserByCredentials(ctx, map[string]interface{}{"id": key}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected key revoked by ID to be rejected, got %v", err)
+	}
+	if err := restored.RevokeID(ctx, id); err != core.ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials for unknown ID, got %v", err)
+	}
+}
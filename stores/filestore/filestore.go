@@ -0,0 +1,215 @@
+// Package filestore provides a UserStore whose users and API keys are defined in a YAML or JSON
+// file. The file is polled for changes and reloaded into a fresh snapshot that is swapped in
+// atomically; a file that is empty, fails to parse or validate, or changes while being read
+// leaves the previous snapshot in use.
+//
+// The file format is:
+//
+//	users:
+//	  - id: u1
+//	    attributes: {username: alice, password_hash: "$2a$10$...", roles: [admin]}
+//	api_keys:
+//	  - key: k-123
+//	    user: u1
+//	  - id: 6hq3kz2xqe4tbmtg
+//	    digest: 9f86d081884c7d65...
+//	    user: u1
+//
+// API keys are held in a stores.APIKeyStore, so no plaintext key is kept in memory. To keep
+// plaintext out of the file too, give a key by the public ID and digest returned by
+// APIKeyStore.Digest instead.
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-ez-auth/core"
+	"go-ez-auth/stores"
+	"go-ez-auth/stores/internal/watch"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// APIKeyCriterion is the FindUserByCredentials criterion holding an API key. Configure the
+// apikey strategy with CredKey: APIKeyCriterion to authenticate keys against the store.
+const APIKeyCriterion = "api_key"
+
+// Config holds settings for a Store.
+type Config struct {
+	Path         string            // .yaml, .yml or .json file
+	PollInterval time.Duration     // how often to check the file for changes; default 2s, negative disables polling
+	Events       core.EventEmitter // optional; receives EventStoreReloaded and EventStoreReloadFailed
+	// APIKeys sets the prefix and pepper the file's API keys and digests were made with.
+	APIKeys stores.APIKeyStoreConfig
+}
+
+// Store serves users from the last valid version of a file. It is safe for concurrent use.
+// Close stops the polling goroutine.
+type Store struct {
+	file *watch.File[*snapshot]
+}
+
+// snapshot is one loaded version of the file.
+type snapshot struct {
+	users *stores.InMemoryUserStore
+	keys  *stores.APIKeyStore
+}
+
+// file is the decoded file format.
+type file struct {
+	Users []struct {
+		ID         string                 `json:"id" yaml:"id"`
+		Attributes map[string]interface{} `json:"attributes" yaml:"attributes"`
+	} `json:"users" yaml:"users"`
+	APIKeys []struct {
+		Key    string `json:"key" yaml:"key"`
+		ID     string `json:"id" yaml:"id"`
+		Digest string `json:"digest" yaml:"digest"`
+		User   string `json:"user" yaml:"user"`
+	} `json:"api_keys" yaml:"api_keys"`
+}
+
+// New loads the file at config.Path and, unless polling is disabled, starts watching it.
+// The initial load must succeed.
+func New(config Config) (*Store, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("filestore: Path is required: %w", core.ErrInvalidConfig)
+	}
+	if config.PollInterval == 0 {
+		config.PollInterval = 2 * time.Second
+	}
+	decode, err := decoder(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := watch.New(watch.Config[*snapshot]{
+		Name:         "filestore",
+		Path:         config.Path,
+		PollInterval: config.PollInterval,
+		Events:       config.Events,
+		Parse: func(data []byte) (*snapshot, error) {
+			var doc file
+			if err := decode(data, &doc); err != nil {
+				return nil, fmt.Errorf("decode: %w", err)
+			}
+			return doc.snapshot(config.APIKeys)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{file: f}, nil
+}
+
+// Reload reads the file now and swaps it in if it is valid. On failure the previous snapshot
+// stays in use, and the error is returned and kept for Err until the next successful reload.
+func (s *Store) Reload() error {
+	return s.file.Reload()
+}
+
+// Err returns the error of the last reload, or nil if it succeeded.
+func (s *Store) Err() error {
+	return s.file.Err()
+}
+
+// Close stops watching the file. The store keeps serving its last snapshot.
+func (s *Store) Close() error {
+	return s.file.Close()
+}
+
+// decoder returns the strict decoder for path's extension.
+func decoder(path string) (func(data []byte, f *file) error, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return func(data []byte, f *file) error {
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.DisallowUnknownFields()
+			return dec.Decode(f)
+		}, nil
+	case ".yaml", ".yml":
+		return func(data []byte, f *file) error {
+			dec := yaml.NewDecoder(bytes.NewReader(data))
+			dec.KnownFields(true)
+			err := dec.Decode(f)
+			if errors.Is(err, io.EOF) {
+				return errors.New("no document") // e.g. only comments, as while being written
+			}
+			return err
+		}, nil
+	}
+	return nil, fmt.Errorf("filestore: unsupported file extension %q", filepath.Ext(path))
+}
+
+// snapshot validates f and indexes it, hashing API keys with keyConfig.
+func (f *file) snapshot(keyConfig stores.APIKeyStoreConfig) (*snapshot, error) {
+	ctx := context.Background()
+	snap := &snapshot{users: stores.NewInMemoryUserStore(), keys: stores.NewAPIKeyStoreWithConfig(keyConfig, nil)}
+	for i, u := range f.Users {
+		if u.ID == "" {
+			return nil, fmt.Errorf("user %d: id is required", i)
+		}
+		if hash, ok := u.Attributes[stores.PasswordHashAttribute]; ok {
+			h, _ := hash.(string)
+			if _, err := bcrypt.Cost([]byte(h)); err != nil {
+				return nil, fmt.Errorf("user %q: %s is not a bcrypt hash", u.ID, stores.PasswordHashAttribute)
+			}
+		}
+		if err := snap.users.CreateUser(ctx, &stores.User{ID: u.ID, Attributes: u.Attributes}); err != nil {
+			return nil, fmt.Errorf("user %q: %w", u.ID, err)
+		}
+	}
+	seen := make(map[string]bool) // key IDs, or whole keys not in the store's format
+	for i, k := range f.APIKeys {
+		if (k.Key == "") == (k.ID == "") {
+			return nil, fmt.Errorf("api key %d: exactly one of key or id is required", i)
+		}
+		id := k.ID
+		if k.Key != "" {
+			if id, _, _ = snap.keys.Digest(k.Key); id == "" {
+				id = k.Key
+			}
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("api key %d: duplicate key", i)
+		}
+		seen[id] = true
+		u, err := snap.users.FindUserByID(ctx, k.User)
+		if err != nil {
+			return nil, fmt.Errorf("api key %d: user %q: %w", i, k.User, err)
+		}
+		if k.Key != "" {
+			snap.keys.Add(k.Key, u)
+		} else if err := snap.keys.AddDigest(k.ID, k.Digest, u); err != nil {
+			return nil, fmt.Errorf("api key %d: %w", i, err)
+		}
+	}
+	return snap, nil
+}
+
+// current returns the snapshot in use.
+func (s *Store) current() *snapshot {
+	return s.file.Current()
+}
+
+// FindUserByID retrieves a user by ID.
+func (s *Store) FindUserByID(ctx context.Context, id string) (core.User, error) {
+	return s.current().users.FindUserByID(ctx, id)
+}
+
+// FindUserByCredentials looks a user up by APIKeyCriterion or, otherwise, by the criteria
+// stores.InMemoryUserStore accepts: "id", "username" or "email", with an optional "password".
+func (s *Store) FindUserByCredentials(ctx context.Context, criteria map[string]interface{}) (core.User, error) {
+	snap := s.current()
+	if key, ok := criteria[APIKeyCriterion].(string); ok {
+		return snap.keys.FindUserByCredentials(ctx, map[string]interface{}{APIKeyCriterion: key})
+	}
+	return snap.users.FindUserByCredentials(ctx, criteria)
+}
@@ -0,0 +1,181 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go-ez-auth/core"
+	"go-ez-auth/stores"
+	"go-ez-auth/stores/filestore"
+)
+
+const usersYAML = `
+users:
+  - id: u1
+    attributes: {username: alice, roles: [admin]}
+api_keys:
+  - key: k-123
+    user: u1
+`
+
+// write atomically replaces the file at path, through a temporary file and rename so the
+// poller never sees it half written, and dates it age ago so the change is seen even on
+// filesystems with coarse timestamps.
+func write(t *testing.T, path, data string, age time.Duration) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mod := time.Now().Add(-age)
+	if err := os.Chtimes(tmp, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	write(t, path, usersYAML, 0)
+	s, err := filestore.New(filestore.Config{Path: path, PollInterval: -1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if u, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "ALICE"}); err != nil || u.GetID() != "u1" {
+		t.Errorf("expected lookup by username, got %v %v", u, err)
+	}
+	if u, err := s.FindUserByCredentials(ctx, map[string]interface{}{filestore.APIKeyCriterion: "k-123"}); err != nil || u.GetID() != "u1" {
+		t.Errorf("expected lookup by api key, got %v %v", u, err)
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{filestore.APIKeyCriterion: "nope"}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestStore_APIKeyDigests(t *testing.T) {
+	issuer := stores.NewAPIKeyStore(nil)
+	key, _ := issuer.Issue(context.Background(), &stores.User{ID: "u1"})
+	id, digest, _ := issuer.Digest(key)
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	write(t, path, "users: [{id: u1}]\napi_keys:\n  - {id: "+id+", digest: "+digest+", user: u1}\n", 0)
+	s, err := filestore.New(filestore.Config{Path: path, PollInterval: -1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if u, err := s.FindUserByCredentials(ctx, map[string]interface{}{filestore.APIKeyCriterion: key}); err != nil || u.GetID() != "u1" {
+		t.Errorf("expected key configured by digest to authenticate, got %v %v", u, err)
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{filestore.APIKeyCriterion: key + "x"}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected a wrong secret to be rejected, got %v", err)
+	}
+}
+
+func TestStore_Validation(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"missing id":       `{"users": [{"attributes": {}}]}`,
+		"duplicate user":   `{"users": [{"id": "u1"}, {"id": "u1"}]}`,
+		"bad hash":         `{"users": [{"id": "u1", "attributes": {"password_hash": "plain"}}]}`,
+		"unknown key user": `{"users": [{"id": "u1"}], "api_keys": [{"key": "k", "user": "u2"}]}`,
+		"duplicate key":    `{"users": [{"id": "u1"}], "api_keys": [{"key": "k", "user": "u1"}, {"key": "k", "user": "u1"}]}`,
+		"key and id":       `{"users": [{"id": "u1"}], "api_keys": [{"key": "k", "id": "i", "user": "u1"}]}`,
+		"bad digest":       `{"users": [{"id": "u1"}], "api_keys": [{"id": "i", "digest": "nothex", "user": "u1"}]}`,
+		"unknown field":    `{"userz": []}`,
+	}
+	for name, data := range cases {
+		path := filepath.Join(dir, "users.json")
+		write(t, path, data, 0)
+		if _, err := filestore.New(filestore.Config{Path: path, PollInterval: -1}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := filestore.New(filestore.Config{}); !errors.Is(err, core.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig without a path, got %v", err)
+	}
+}
+
+func TestStore_HotReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	write(t, path, usersYAML, time.Hour)
+	events := core.NewAuthenticator()
+	var mu sync.Mutex
+	var got []core.Event
+	events.Subscribe(func(ctx context.Context, e core.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, e)
+	})
+	s, err := filestore.New(filestore.Config{Path: path, PollInterval: 5 * time.Millisecond, Events: events})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+	waitFor := func(n int) []core.Event {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			if len(got) >= n {
+				defer mu.Unlock()
+				return append([]core.Event(nil), got...)
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %d reload events", n)
+		return nil
+	}
+
+	// A broken edit is reported and the previous snapshot stays in use.
+	write(t, path, "users: [", 30*time.Minute)
+	if e := waitFor(1)[0]; e.Type != core.EventStoreReloadFailed || e.Err == nil {
+		t.Errorf("expected a reload failure event, got %+v", e)
+	}
+	if s.Err() == nil {
+		t.Error("expected Err to report the broken file")
+	}
+	if _, err := s.FindUserByID(ctx, "u1"); err != nil {
+		t.Errorf("expected the previous snapshot to be kept, got %v", err)
+	}
+
+	// A valid edit replaces the snapshot.
+	write(t, path, "users: [{id: u2}]\n", 0)
+	if e := waitFor(2)[1]; e.Type != core.EventStoreReloaded {
+		t.Errorf("expected a reload event, got %+v", e)
+	}
+	if s.Err() != nil {
+		t.Errorf("expected Err to be cleared, got %v", s.Err())
+	}
+	if _, err := s.FindUserByID(ctx, "u1"); err != core.ErrUserNotFound {
+		t.Errorf("expected u1 to be gone, got %v", err)
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{filestore.APIKeyCriterion: "k-123"}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected k-123 to be gone, got %v", err)
+	}
+	if _, err := s.FindUserByID(ctx, "u2"); err != nil {
+		t.Errorf("expected u2 to be loaded, got %v", err)
+	}
+
+	// A file caught empty, as mid-way through an in-place write, is not taken as having no users.
+	write(t, path, "", time.Hour)
+	if e := waitFor(3)[2]; e.Type != core.EventStoreReloadFailed {
+		t.Errorf("expected an empty file to fail to reload, got %+v", e)
+	}
+	if _, err := s.FindUserByID(ctx, "u2"); err != nil {
+		t.Errorf("expected u2 to be kept, got %v", err)
+	}
+}
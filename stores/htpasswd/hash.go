@@ -0,0 +1,127 @@
+package htpasswd
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// errUnsupportedHash is returned for entries that are not bcrypt, {SHA} or $apr1$ hashes.
+var errUnsupportedHash = errors.New("unsupported hash format; use bcrypt, {SHA} or $apr1$")
+
+// checkHash validates the format of an htpasswd hash without checking a password.
+func checkHash(hash string) error {
+	switch {
+	case isBcrypt(hash):
+		_, err := bcrypt.Cost([]byte(hash))
+		return err
+	case strings.HasPrefix(hash, "{SHA}"):
+		if b, err := base64.StdEncoding.DecodeString(hash[len("{SHA}"):]); err != nil || len(b) != sha1.Size {
+			return errors.New("malformed {SHA} hash")
+		}
+		return nil
+	case strings.HasPrefix(hash, apr1Magic):
+		if strings.Count(hash, "$") != 3 {
+			return errors.New("malformed $apr1$ hash")
+		}
+		return nil
+	}
+	return errUnsupportedHash
+}
+
+// matches reports whether password matches an htpasswd hash.
+func matches(hash, password string) bool {
+	switch {
+	case isBcrypt(hash):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		return constantTimeEqual(hash, "{SHA}"+base64.StdEncoding.EncodeToString(sum[:]))
+	case strings.HasPrefix(hash, apr1Magic):
+		salt, _, _ := strings.Cut(hash[len(apr1Magic):], "$")
+		return constantTimeEqual(hash, apr1(password, salt))
+	}
+	return false
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+const (
+	apr1Magic = "$apr1$"
+	itoa64    = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// apr1 computes Apache's MD5-based crypt variant, returning "$apr1$salt$digest".
+func apr1(password, salt string) string {
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+
+	alt := md5.New()
+	alt.Write(pw)
+	alt.Write([]byte(salt))
+	alt.Write(pw)
+	altSum := alt.Sum(nil)
+
+	h := md5.New()
+	h.Write(pw)
+	h.Write([]byte(apr1Magic + salt))
+	for i := len(pw); i > 0; i -= md5.Size {
+		h.Write(altSum[:min(i, md5.Size)])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(pw[:1])
+		}
+	}
+	sum := h.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		r := md5.New()
+		if i&1 == 1 {
+			r.Write(pw)
+		} else {
+			r.Write(sum)
+		}
+		if i%3 != 0 {
+			r.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			r.Write(pw)
+		}
+		if i&1 == 1 {
+			r.Write(sum)
+		} else {
+			r.Write(pw)
+		}
+		sum = r.Sum(nil)
+	}
+
+	var b strings.Builder
+	b.WriteString(apr1Magic + salt + "$")
+	encode := func(v uint32, n int) {
+		for ; n > 0; n-- {
+			b.WriteByte(itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, g := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint32(sum[g[0]])<<16|uint32(sum[g[1]])<<8|uint32(sum[g[2]]), 4)
+	}
+	encode(uint32(sum[11]), 2)
+	return b.String()
+}
@@ -0,0 +1,243 @@
+// Package htpasswd provides a UserStore backed by an Apache htpasswd file, as maintained for
+// nginx or Apache basic auth. Entries may be bcrypt ($2y$, $2a$, $2b$), SHA1 ({SHA}) or
+// APR1-MD5 ($apr1$) hashes. The file is polled and reloaded when it changes, and Set and
+// Delete rewrite it atomically. New passwords are always hashed with bcrypt.
+//
+// Plug it into the local strategy, which passes "username" and "password" criteria:
+//
+//	users, err := htpasswd.New(htpasswd.Config{Path: "/etc/nginx/.htpasswd"})
+//	auth.Register(local.New(local.Config{UserStore: users}))
+package htpasswd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go-ez-auth/core"
+	"go-ez-auth/stores"
+	"go-ez-auth/stores/internal/watch"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config holds settings for a Store.
+type Config struct {
+	Path         string
+	PollInterval time.Duration     // how often to check the file for changes; default 2s, negative disables polling
+	Cost         int               // bcrypt cost for Set; default bcrypt.DefaultCost
+	Events       core.EventEmitter // optional; receives EventStoreReloaded and EventStoreReloadFailed
+}
+
+// Store serves users from the last valid version of an htpasswd file. User IDs are usernames,
+// also exposed as the stores.UsernameAttribute. It is safe for concurrent use. Close stops the
+// polling goroutine.
+type Store struct {
+	config Config
+	file   *watch.File[*snapshot]
+	wmu    sync.Mutex // serializes Set and Delete
+}
+
+// snapshot is one loaded version of the file. lines keeps the file verbatim, including blank
+// lines and comments, so rewrites only touch the entry being changed.
+type snapshot struct {
+	lines  []string
+	hashes map[string]string // username -> hash
+	index  map[string]int    // username -> line
+}
+
+// New loads the htpasswd file at config.Path and, unless polling is disabled, starts watching
+// it. The initial load must succeed.
+func New(config Config) (*Store, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("htpasswd: Path is required: %w", core.ErrInvalidConfig)
+	}
+	if config.PollInterval == 0 {
+		config.PollInterval = 2 * time.Second
+	}
+	if config.Cost == 0 {
+		config.Cost = bcrypt.DefaultCost
+	}
+	f, err := watch.New(watch.Config[*snapshot]{
+		Name:         "htpasswd",
+		Path:         config.Path,
+		PollInterval: config.PollInterval,
+		Events:       config.Events,
+		Parse:        parse,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{config: config, file: f}, nil
+}
+
+// Reload reads the file now and swaps it in if it is valid. On failure the previous snapshot
+// stays in use, and the error is returned and kept for Err until the next successful reload.
+func (s *Store) Reload() error {
+	return s.file.Reload()
+}
+
+// Err returns the error of the last reload, or nil if it succeeded.
+func (s *Store) Err() error {
+	return s.file.Err()
+}
+
+// Close stops watching the file. The store keeps serving its last snapshot.
+func (s *Store) Close() error {
+	return s.file.Close()
+}
+
+// parse splits data into lines and indexes its "username:hash" entries.
+func parse(data []byte) (*snapshot, error) {
+	snap := &snapshot{hashes: make(map[string]string), index: make(map[string]int)}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		snap.lines = append(snap.lines, line)
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(trimmed, ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("line %d: expected username:hash", n)
+		}
+		if _, dup := snap.hashes[username]; dup {
+			return nil, fmt.Errorf("line %d: duplicate user %q", n, username)
+		}
+		if err := checkHash(hash); err != nil {
+			return nil, fmt.Errorf("line %d: user %q: %w", n, username, err)
+		}
+		snap.hashes[username] = hash
+		snap.index[username] = len(snap.lines) - 1
+	}
+	return snap, sc.Err()
+}
+
+// current returns the snapshot in use.
+func (s *Store) current() *snapshot {
+	return s.file.Current()
+}
+
+// user returns the core.User for username.
+func user(username string) core.User {
+	return &stores.User{ID: username, Attributes: map[string]interface{}{stores.UsernameAttribute: username}}
+}
+
+// FindUserByID retrieves a user by username.
+func (s *Store) FindUserByID(ctx context.Context, id string) (core.User, error) {
+	if _, ok := s.current().hashes[id]; !ok {
+		return nil, core.ErrUserNotFound
+	}
+	return user(id), nil
+}
+
+// FindUserByCredentials looks a user up by the "username" (or "id") criterion. If a "password"
+// criterion is given, it must be a string matching the user's hash. Anything else returns
+// core.ErrInvalidCredentials.
+func (s *Store) FindUserByCredentials(ctx context.Context, criteria map[string]interface{}) (core.User, error) {
+	username, ok := criteria[stores.UsernameAttribute].(string)
+	if !ok {
+		username, _ = criteria["id"].(string)
+	}
+	hash, ok := s.current().hashes[username]
+	if !ok {
+		return nil, core.ErrInvalidCredentials
+	}
+	if p, given := criteria["password"]; given {
+		if password, ok := p.(string); !ok || !matches(hash, password) {
+			return nil, core.ErrInvalidCredentials
+		}
+	}
+	return user(username), nil
+}
+
+// Set adds username or replaces its password, storing a bcrypt hash, and rewrites the file.
+func (s *Store) Set(username, password string) error {
+	if username == "" || strings.ContainsAny(username, ":\r\n") {
+		return fmt.Errorf("htpasswd: invalid username %q", username)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.config.Cost)
+	if err != nil {
+		return fmt.Errorf("htpasswd: hash password: %w", err)
+	}
+	return s.rewrite(func(snap *snapshot) error {
+		entry := username + ":" + string(hash)
+		if i, ok := snap.index[username]; ok {
+			snap.lines[i] = entry
+		} else {
+			snap.lines = append(snap.lines, entry)
+		}
+		return nil
+	})
+}
+
+// Delete removes username and rewrites the file, failing with core.ErrUserNotFound if there is
+// no such user.
+func (s *Store) Delete(username string) error {
+	return s.rewrite(func(snap *snapshot) error {
+		i, ok := snap.index[username]
+		if !ok {
+			return core.ErrUserNotFound
+		}
+		snap.lines = append(snap.lines[:i], snap.lines[i+1:]...)
+		return nil
+	})
+}
+
+// rewrite applies edit to a fresh read of the file, writes the result through a temporary file
+// and rename, and reloads it, publishing EventStoreReloaded like any other change. A file that
+// does not currently parse is not overwritten.
+func (s *Store) rewrite(edit func(snap *snapshot) error) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	snap, err := s.file.Load()
+	if err != nil {
+		return err
+	}
+	if err := edit(snap); err != nil {
+		return err
+	}
+	data := []byte(strings.Join(snap.lines, "\n") + "\n")
+	if _, err := parse(data); err != nil {
+		return fmt.Errorf("htpasswd: %w", err)
+	}
+	if err := writeFile(s.config.Path, data); err != nil {
+		return fmt.Errorf("htpasswd: %w", err)
+	}
+	return s.file.Reload()
+}
+
+// writeFile atomically replaces path with data, keeping its permissions.
+func writeFile(path string, data []byte) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(fi.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,155 @@
+package htpasswd_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-ez-auth/core"
+	"go-ez-auth/stores/htpasswd"
+	"go-ez-auth/strategies/local"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newFile writes an htpasswd file with a bcrypt ($2y$), a SHA1 and an APR1 entry, each with
+// the password "hunter2" except apr, whose password is "s3cret".
+func newFile(t *testing.T) string {
+	t.Helper()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	lines := []string{
+		"# managed by ops",
+		"bc:" + strings.Replace(string(hash), "$2a$", "$2y$", 1),
+		"sha:{SHA}87u9ZqY9S/F0eUBXjsPQEDUw4h0=",
+		"apr:$apr1$saltsalt$64vPg1.FPS6FtcYJ7Ti1V.",
+	}
+	path := filepath.Join(t.TempDir(), ".htpasswd")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStore_Formats(t *testing.T) {
+	s, err := htpasswd.New(htpasswd.Config{Path: newFile(t), PollInterval: -1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+	for user, password := range map[string]string{"bc": "hunter2", "sha": "hunter2", "apr": "s3cret"} {
+		if u, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": user, "password": password}); err != nil || u.GetID() != user {
+			t.Errorf("%s: expected password to match, got %v %v", user, u, err)
+		}
+		if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": user, "password": "wrong"}); err != core.ErrInvalidCredentials {
+			t.Errorf("%s: expected ErrInvalidCredentials, got %v", user, err)
+		}
+		if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": user, "password": nil}); err != core.ErrInvalidCredentials {
+			t.Errorf("%s: expected non-string password to be rejected, got %v", user, err)
+		}
+	}
+	if _, err := s.FindUserByID(ctx, "nope"); err != core.ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestStore_LocalStrategy(t *testing.T) {
+	s, err := htpasswd.New(htpasswd.Config{Path: newFile(t), PollInterval: -1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	strat := local.New(local.Config{UserStore: s})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.SetBasicAuth("apr", "s3cret")
+	if u, err := strat.Authenticate(r.Context(), r); err != nil || u.GetID() != "apr" {
+		t.Errorf("expected basic auth to succeed, got %v %v", u, err)
+	}
+}
+
+func TestStore_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".htpasswd")
+	for _, data := range []string{"bob\n", "bob:plaintext\n", "bob:{SHA}x\nbob:{SHA}x\n"} {
+		os.WriteFile(path, []byte(data), 0o600)
+		if _, err := htpasswd.New(htpasswd.Config{Path: path, PollInterval: -1}); err == nil {
+			t.Errorf("expected %q to be rejected", data)
+		}
+	}
+}
+
+func TestStore_SetDeleteAndReload(t *testing.T) {
+	path := newFile(t)
+	s, err := htpasswd.New(htpasswd.Config{Path: path, PollInterval: 5 * time.Millisecond, Cost: bcrypt.MinCost})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if err := s.Set("sha", "changed"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := s.Set("new", "pw"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := s.Delete("bc"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete("bc"); err != core.ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+	if err := s.Set("a:b", "pw"); err == nil {
+		t.Error("expected a username containing ':' to be rejected")
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "sha", "password": "changed"}); err != nil {
+		t.Errorf("expected the updated password to match, got %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "# managed by ops\nsha:$2a$") || !strings.Contains(string(data), "\nnew:$2a$") {
+		t.Errorf("expected the comment kept and entries rewritten in place, got:\n%s", data)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o640 {
+		t.Errorf("expected permissions to be kept, got %v", fi.Mode().Perm())
+	}
+
+	// An external edit is picked up by polling.
+	if err := os.WriteFile(path, []byte("only:{SHA}87u9ZqY9S/F0eUBXjsPQEDUw4h0=\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	mod := time.Now().Add(time.Minute)
+	os.Chtimes(path, mod, mod)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := s.FindUserByID(ctx, "only"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for reload")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := s.FindUserByID(ctx, "new"); err != core.ErrUserNotFound {
+		t.Errorf("expected the reload to replace all users, got %v", err)
+	}
+}
+
+func TestStore_RewriteEvents(t *testing.T) {
+	events := core.NewAuthenticator()
+	var got []core.EventType
+	events.Subscribe(func(ctx context.Context, e core.Event) { got = append(got, e.Type) })
+	s, err := htpasswd.New(htpasswd.Config{Path: newFile(t), PollInterval: -1, Cost: bcrypt.MinCost, Events: events})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+
+	// Subscribers such as the cache decorator must hear about changes made through the store.
+	s.Set("sha", "changed")
+	s.Delete("bc")
+	s.Delete("bc")
+	if len(got) != 2 || got[0] != core.EventStoreReloaded || got[1] != core.EventStoreReloaded {
+		t.Errorf("expected a reload event for each rewrite, got %v", got)
+	}
+}
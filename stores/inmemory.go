@@ -2,35 +2,208 @@ package stores
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
 	"go-ez-auth/core"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Attribute names the InMemoryUserStore indexes and checks. Usernames and emails are unique
+// and matched case-insensitively.
+const (
+	UsernameAttribute     = "username"
+	EmailAttribute        = "email"
+	PasswordHashAttribute = "password_hash" // bcrypt hash checked against a "password" criterion
 )
 
-// InMemoryUserStore is a simple UserStore backed by an in-memory map.
+// InMemoryUserStore is a UserStore and core.UserWriter backed by in-memory maps, with secondary
+// indexes on the username and email attributes. It is safe for concurrent use.
 type InMemoryUserStore struct {
-	users map[string]core.User
+	mu         sync.RWMutex
+	users      map[string]core.User
+	byUsername map[string]string // lowercased username -> ID
+	byEmail    map[string]string // lowercased email -> ID
 }
 
-// NewInMemoryUserStore creates a new store with optional initial users.
+// NewInMemoryUserStore creates a new store with optional initial users. Later users replace
+// earlier ones with the same ID.
 func NewInMemoryUserStore(initialUsers ...core.User) *InMemoryUserStore {
-	m := make(map[string]core.User)
+	s := &InMemoryUserStore{
+		users:      make(map[string]core.User),
+		byUsername: make(map[string]string),
+		byEmail:    make(map[string]string),
+	}
 	for _, u := range initialUsers {
-		m[u.GetID()] = u
+		s.unindex(u.GetID())
+		s.index(u)
+	}
+	return s
+}
+
+// indexKeys returns the lowercased username and email attributes of u, if set.
+func indexKeys(u core.User) (username, email string) {
+	attrs := u.GetAttributes()
+	username, _ = attrs[UsernameAttribute].(string)
+	email, _ = attrs[EmailAttribute].(string)
+	return strings.ToLower(username), strings.ToLower(email)
+}
+
+// index adds u to the maps. s.mu must be held.
+func (s *InMemoryUserStore) index(u core.User) {
+	s.users[u.GetID()] = u
+	username, email := indexKeys(u)
+	if username != "" {
+		s.byUsername[username] = u.GetID()
+	}
+	if email != "" {
+		s.byEmail[email] = u.GetID()
 	}
-	return &InMemoryUserStore{users: m}
+}
+
+// unindex removes the user with id from the maps. s.mu must be held.
+func (s *InMemoryUserStore) unindex(id string) {
+	u, ok := s.users[id]
+	if !ok {
+		return
+	}
+	delete(s.users, id)
+	username, email := indexKeys(u)
+	if s.byUsername[username] == id {
+		delete(s.byUsername, username)
+	}
+	if s.byEmail[email] == id {
+		delete(s.byEmail, email)
+	}
+}
+
+// checkUnique returns ErrUserExists if u's username or email belongs to a user other than u.
+// s.mu must be held.
+func (s *InMemoryUserStore) checkUnique(u core.User) error {
+	username, email := indexKeys(u)
+	if id, ok := s.byUsername[username]; ok && username != "" && id != u.GetID() {
+		return fmt.Errorf("stores: username %q: %w", username, core.ErrUserExists)
+	}
+	if id, ok := s.byEmail[email]; ok && email != "" && id != u.GetID() {
+		return fmt.Errorf("stores: email %q: %w", email, core.ErrUserExists)
+	}
+	return nil
 }
 
 // FindUserByID retrieves a user by ID.
 func (s *InMemoryUserStore) FindUserByID(ctx context.Context, id string) (core.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	if u, ok := s.users[id]; ok {
 		return u, nil
 	}
 	return nil, core.ErrUserNotFound
 }
 
-// FindUserByCredentials supports lookup by "id" field in criteria, else returns ErrInvalidCredentials.
+// FindUserByCredentials looks a user up by the "id", "username" or "email" criteria; every one
+// given must refer to the same user. If a "password" criterion is given, it must be a string
+// matching the user's bcrypt PasswordHashAttribute. Anything else returns core.ErrInvalidCredentials.
 func (s *InMemoryUserStore) FindUserByCredentials(ctx context.Context, criteria map[string]interface{}) (core.User, error) {
-	if idVal, ok := criteria["id"].(string); ok {
-		return s.FindUserByID(ctx, idVal)
+	u, ok := s.lookup(criteria)
+	if !ok {
+		return nil, core.ErrInvalidCredentials
+	}
+	if p, given := criteria["password"]; given {
+		password, ok := p.(string)
+		hash, _ := u.GetAttributes()[PasswordHashAttribute].(string)
+		if !ok || hash == "" || bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			return nil, core.ErrInvalidCredentials
+		}
+	}
+	return u, nil
+}
+
+// lookup finds the single user matched by the identifying criteria.
+func (s *InMemoryUserStore) lookup(criteria map[string]interface{}) (core.User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lookups := []struct {
+		key   string
+		index map[string]string // nil for the ID itself
+	}{{"id", nil}, {"username", s.byUsername}, {"email", s.byEmail}}
+	var id string
+	for _, l := range lookups {
+		val, ok := criteria[l.key].(string)
+		if !ok {
+			continue
+		}
+		if l.index != nil {
+			val = l.index[strings.ToLower(val)]
+		}
+		if val == "" || (id != "" && val != id) {
+			return nil, false
+		}
+		id = val
+	}
+	u, ok := s.users[id]
+	return u, ok
+}
+
+// CreateUser adds user, failing with core.ErrUserExists if its ID, username or email is taken.
+func (s *InMemoryUserStore) CreateUser(ctx context.Context, user core.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[user.GetID()]; ok {
+		return fmt.Errorf("stores: id %q: %w", user.GetID(), core.ErrUserExists)
+	}
+	if err := s.checkUnique(user); err != nil {
+		return err
+	}
+	s.index(user)
+	return nil
+}
+
+// UpdateUser replaces the user with the same ID, failing with core.ErrUserNotFound if there is
+// none or core.ErrUserExists if the new username or email belongs to someone else.
+func (s *InMemoryUserStore) UpdateUser(ctx context.Context, user core.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[user.GetID()]; !ok {
+		return core.ErrUserNotFound
+	}
+	if err := s.checkUnique(user); err != nil {
+		return err
+	}
+	s.unindex(user.GetID())
+	s.index(user)
+	return nil
+}
+
+// DeleteUser removes the user with id, failing with core.ErrUserNotFound if there is none.
+func (s *InMemoryUserStore) DeleteUser(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[id]; !ok {
+		return core.ErrUserNotFound
+	}
+	s.unindex(id)
+	return nil
+}
+
+// ListUsers returns a page of users ordered by ID.
+func (s *InMemoryUserStore) ListUsers(ctx context.Context, opts core.ListOptions) ([]core.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.users))
+	for id := range s.users {
+		if id > opts.After {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if opts.Limit > 0 && len(ids) > opts.Limit {
+		ids = ids[:opts.Limit]
+	}
+	users := make([]core.User, len(ids))
+	for i, id := range ids {
+		users[i] = s.users[id]
 	}
-	return nil, core.ErrInvalidCredentials
+	return users, nil
 }
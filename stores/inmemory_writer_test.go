@@ -0,0 +1,110 @@
+package stores_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"go-ez-auth/core"
+	"go-ez-auth/stores"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestInMemoryUserStore_CRUD(t *testing.T) {
+	ctx := context.Background()
+	s := stores.NewInMemoryUserStore()
+	var _ core.UserWriter = s
+
+	alice := &stores.User{ID: "u1", Attributes: map[string]interface{}{"username": "Alice", "email": "alice@example.com"}}
+	if err := s.CreateUser(ctx, alice); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := s.CreateUser(ctx, &stores.User{ID: "u1"}); !errors.Is(err, core.ErrUserExists) {
+		t.Errorf("expected ErrUserExists for duplicate ID, got %v", err)
+	}
+	if err := s.CreateUser(ctx, &stores.User{ID: "u2", Attributes: map[string]interface{}{"username": "alice"}}); !errors.Is(err, core.ErrUserExists) {
+		t.Errorf("expected ErrUserExists for duplicate username, got %v", err)
+	}
+
+	if u, err := s.FindUserByCredentials(ctx, map[string]interface{}{"email": "ALICE@example.com"}); err != nil || u.GetID() != "u1" {
+		t.Errorf("expected lookup by email, got %v %v", u, err)
+	}
+
+	renamed := &stores.User{ID: "u1", Attributes: map[string]interface{}{"username": "alicia"}}
+	if err := s.UpdateUser(ctx, renamed); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "alice"}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected old username to be unindexed, got %v", err)
+	}
+	if err := s.UpdateUser(ctx, &stores.User{ID: "nope"}); err != core.ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+
+	if err := s.DeleteUser(ctx, "u1"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "alicia"}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected deleted user to be unindexed, got %v", err)
+	}
+	if err := s.DeleteUser(ctx, "u1"); err != core.ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestInMemoryUserStore_Password(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	s := stores.NewInMemoryUserStore(&stores.User{ID: "u1", Attributes: map[string]interface{}{"username": "bob", "password_hash": string(hash)}})
+	ctx := context.Background()
+
+	if u, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "bob", "password": "s3cret"}); err != nil || u.GetID() != "u1" {
+		t.Errorf("expected valid password to match, got %v %v", u, err)
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "bob", "password": "wrong"}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+	for _, password := range []interface{}{nil, []byte("s3cret"), 42} {
+		if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "bob", "password": password}); err != core.ErrInvalidCredentials {
+			t.Errorf("expected non-string password %#v to be rejected, got %v", password, err)
+		}
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"id": "u1", "username": "someone-else"}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected conflicting criteria to fail, got %v", err)
+	}
+}
+
+func TestInMemoryUserStore_ListAndConcurrency(t *testing.T) {
+	ctx := context.Background()
+	s := stores.NewInMemoryUserStore()
+	var wg sync.WaitGroup
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.CreateUser(ctx, &stores.User{ID: fmt.Sprintf("u%02d", i)})
+			s.FindUserByID(ctx, "u00")
+		}(i)
+	}
+	wg.Wait()
+
+	var ids []string
+	after := ""
+	for {
+		page, err := s.ListUsers(ctx, core.ListOptions{After: after, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, u := range page {
+			ids = append(ids, u.GetID())
+		}
+		after = page[len(page)-1].GetID()
+	}
+	if len(ids) != 25 || ids[0] != "u00" || ids[24] != "u24" {
+		t.Errorf("expected 25 users in order, got %v", ids)
+	}
+}
@@ -0,0 +1,174 @@
+// Package watch keeps the parsed contents of a file up to date for the file-backed stores. The
+// file is polled for changes and reparsed into a fresh value that is swapped in atomically; a
+// version that fails to parse leaves the previous value in use. So does a file that is empty
+// or changes while it is read, as when an editor's in-place write is caught half done.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go-ez-auth/core"
+)
+
+// Config holds settings for a File.
+type Config[T any] struct {
+	Name         string                                 // prefixes errors, e.g. "htpasswd"
+	Path         string                                 // file to read
+	PollInterval time.Duration                          // how often to check the file for changes; zero or negative disables polling
+	Events       core.EventEmitter                      // optional; receives EventStoreReloaded and EventStoreReloadFailed
+	Parse        func(data []byte) (value T, err error) // decodes and validates the file's contents
+}
+
+// File holds the last valid version of a parsed file. It is safe for concurrent use. Close stops
+// the polling goroutine.
+type File[T any] struct {
+	config Config[T]
+
+	mu    sync.RWMutex
+	value T
+	stamp stamp
+	err   error
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// stamp identifies a version of the file by modification time and size.
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func newStamp(fi os.FileInfo) stamp {
+	return stamp{modTime: fi.ModTime(), size: fi.Size()}
+}
+
+// New loads the file and, if config.PollInterval is positive, starts watching it. The initial
+// load must succeed.
+func New[T any](config Config[T]) (*File[T], error) {
+	value, st, err := config.load()
+	if err != nil {
+		return nil, err
+	}
+	f := &File[T]{config: config, value: value, stamp: st, stop: make(chan struct{}), done: make(chan struct{})}
+	if config.PollInterval > 0 {
+		go f.watch()
+	} else {
+		close(f.done)
+	}
+	return f, nil
+}
+
+// watch reloads the file whenever its stamp changes, until Close.
+func (f *File[T]) watch() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			fi, err := os.Stat(f.config.Path)
+			if err != nil {
+				continue // editors may briefly remove the file while replacing it
+			}
+			f.mu.RLock()
+			changed := newStamp(fi) != f.stamp
+			f.mu.RUnlock()
+			if changed {
+				f.Reload()
+			}
+		}
+	}
+}
+
+// Current returns the value in use.
+func (f *File[T]) Current() T {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.value
+}
+
+// Load reads and parses the file without swapping it in.
+func (f *File[T]) Load() (T, error) {
+	value, _, err := f.config.load()
+	return value, err
+}
+
+// Reload reads the file now and swaps it in if it is valid. On failure the previous value
+// stays in use, and the error is returned and kept for Err until the next successful reload.
+// Either way the outcome is published to Config.Events.
+func (f *File[T]) Reload() error {
+	value, st, err := f.config.load()
+	f.mu.Lock()
+	if !st.modTime.IsZero() {
+		f.stamp = st // a broken version is not retried until it changes again
+	}
+	f.err = err
+	if err == nil {
+		f.value = value
+	}
+	f.mu.Unlock()
+
+	if f.config.Events != nil {
+		e := core.NewEvent(core.EventStoreReloaded, nil)
+		if err != nil {
+			e.Type, e.Err = core.EventStoreReloadFailed, err
+		}
+		f.config.Events.Emit(context.Background(), e)
+	}
+	return err
+}
+
+// Err returns the error of the last reload, or nil if it succeeded.
+func (f *File[T]) Err() error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.err
+}
+
+// Close stops watching the file. The last value stays in use.
+func (f *File[T]) Close() error {
+	f.closeOnce.Do(func() { close(f.stop) })
+	<-f.done
+	return nil
+}
+
+// Errors for versions of the file that are being written.
+var (
+	errEmpty   = errors.New("file is empty")
+	errChanged = errors.New("file changed while being read")
+)
+
+// load reads and parses the file, returning the stamp of the version read.
+func (c *Config[T]) load() (T, stamp, error) {
+	var zero T
+	fi, err := os.Stat(c.Path)
+	if err != nil {
+		return zero, stamp{}, fmt.Errorf("%s: %w", c.Name, err)
+	}
+	st := newStamp(fi)
+	data, err := os.ReadFile(c.Path)
+	if err != nil {
+		return zero, st, fmt.Errorf("%s: %w", c.Name, err)
+	}
+	if fi, err := os.Stat(c.Path); err != nil || newStamp(fi) != st || int64(len(data)) != st.size {
+		// The stamp read before is kept, so the next poll sees the finished write as a change.
+		return zero, st, fmt.Errorf("%s: %s: %w", c.Name, c.Path, errChanged)
+	}
+	if len(data) == 0 {
+		return zero, st, fmt.Errorf("%s: %s: %w", c.Name, c.Path, errEmpty)
+	}
+	value, err := c.Parse(data)
+	if err != nil {
+		return zero, st, fmt.Errorf("%s: %s: %w", c.Name, c.Path, err)
+	}
+	return value, st, nil
+}
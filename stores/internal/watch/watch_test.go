@@ -0,0 +1,42 @@
+package watch_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-ez-auth/stores/internal/watch"
+)
+
+func TestFile_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "value")
+	os.WriteFile(path, []byte("v1"), 0o600)
+	f, err := watch.New(watch.Config[string]{
+		Name: "test",
+		Path: path,
+		Parse: func(data []byte) (string, error) {
+			if len(data) == 0 {
+				return "", errors.New("empty")
+			}
+			return string(data), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer f.Close()
+
+	os.WriteFile(path, []byte("v2"), 0o600)
+	if v, err := f.Load(); err != nil || v != "v2" || f.Current() != "v1" {
+		t.Errorf("expected Load to read v2 without swapping it in, got %q %v, current %q", v, err, f.Current())
+	}
+	if err := f.Reload(); err != nil || f.Current() != "v2" {
+		t.Errorf("expected v2 after Reload, got %q %v", f.Current(), err)
+	}
+
+	os.WriteFile(path, nil, 0o600)
+	if err := f.Reload(); err == nil || f.Err() == nil || f.Current() != "v2" {
+		t.Errorf("expected a failed reload to keep v2, got %q %v", f.Current(), err)
+	}
+}
@@ -0,0 +1,134 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version    int
+	Statements []string
+}
+
+// Dialect adapts the store's queries and migrations to a database.
+type Dialect struct {
+	Name       string
+	Migrations []Migration // in ascending Version order
+	numbered   bool        // placeholders are $1, $2, ... rather than ?
+}
+
+// rebind rewrites the ? placeholders in query for the dialect.
+func (d *Dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// SQLite is the dialect for SQLite 3.
+var SQLite = &Dialect{
+	Name: "sqlite",
+	Migrations: []Migration{{
+		Version: 1,
+		Statements: []string{
+			`CREATE TABLE auth_users (
+				id TEXT PRIMARY KEY,
+				username TEXT UNIQUE,
+				email TEXT UNIQUE,
+				password_hash TEXT NOT NULL DEFAULT '',
+				attributes TEXT NOT NULL DEFAULT '{}',
+				created_at TIMESTAMP NOT NULL,
+				updated_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE TABLE auth_api_keys (
+				key_hash TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES auth_users(id) ON DELETE CASCADE,
+				created_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX auth_api_keys_user_id ON auth_api_keys (user_id)`,
+		},
+	}},
+}
+
+// Postgres is the dialect for PostgreSQL.
+var Postgres = &Dialect{
+	Name:     "postgres",
+	numbered: true,
+	Migrations: []Migration{{
+		Version: 1,
+		Statements: []string{
+			`CREATE TABLE auth_users (
+				id TEXT PRIMARY KEY,
+				username TEXT UNIQUE,
+				email TEXT UNIQUE,
+				password_hash TEXT NOT NULL DEFAULT '',
+				attributes JSONB NOT NULL DEFAULT '{}',
+				created_at TIMESTAMPTZ NOT NULL,
+				updated_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE TABLE auth_api_keys (
+				key_hash TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES auth_users(id) ON DELETE CASCADE,
+				created_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX auth_api_keys_user_id ON auth_api_keys (user_id)`,
+		},
+	}},
+}
+
+// Migrate brings the schema in db up to date with dialect's migrations, recording applied
+// versions in the auth_schema_migrations table. Each migration runs in its own transaction.
+func Migrate(ctx context.Context, db *sql.DB, dialect *Dialect) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS auth_schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("sqlstore: create migrations table: %w", err)
+	}
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM auth_schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("sqlstore: read schema version: %w", err)
+	}
+	for _, m := range dialect.Migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := apply(ctx, db, dialect, m); err != nil {
+			return fmt.Errorf("sqlstore: migration %d: %w", m.Version, err)
+		}
+	}
+	return nil
+}
+
+// apply runs one migration and records it.
+func apply(ctx context.Context, db *sql.DB, dialect *Dialect, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range m.Statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, dialect.rebind(`INSERT INTO auth_schema_migrations (version, applied_at) VALUES (?, ?)`), m.Version, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
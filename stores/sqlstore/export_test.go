@@ -0,0 +1,4 @@
+package sqlstore
+
+// Rebind exposes Dialect.rebind to tests.
+func (d *Dialect) Rebind(query string) string { return d.rebind(query) }
@@ -0,0 +1,371 @@
+// Package sqlstore provides a UserStore and core.UserWriter backed by database/sql. Users,
+// their bcrypt password hashes, free-form attributes (as a JSON column) and API keys live in
+// tables created by Migrate; the SQLite and Postgres dialects are supported.
+package sqlstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-ez-auth/core"
+	"go-ez-auth/stores"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// APIKeyCriterion is the FindUserByCredentials criterion holding an API key. Configure the
+// apikey strategy with CredKey: APIKeyCriterion to authenticate keys against the store.
+const APIKeyCriterion = "api_key"
+
+// Store is a UserStore and core.UserWriter over a *sql.DB whose schema has been migrated with
+// Migrate. Usernames and emails are unique and matched case-insensitively. API keys are stored
+// only as SHA-256 hashes. It is safe for concurrent use.
+type Store struct {
+	// Events, if set, receives key revoked events.
+	Events core.EventEmitter
+
+	db      *sql.DB
+	dialect *Dialect
+}
+
+// New creates a Store over db. A nil dialect defaults to SQLite.
+func New(db *sql.DB, dialect *Dialect) *Store {
+	if dialect == nil {
+		dialect = SQLite
+	}
+	return &Store{db: db, dialect: dialect}
+}
+
+// Migrate brings the store's schema up to date; see the package-level Migrate.
+func (s *Store) Migrate(ctx context.Context) error {
+	return Migrate(ctx, s.db, s.dialect)
+}
+
+// row is a user as stored in auth_users.
+type row struct {
+	id, passwordHash string
+	username, email  sql.NullString
+	attributes       []byte
+}
+
+// toRow splits u into its indexed columns and the JSON-encoded remaining attributes.
+func toRow(u core.User) (row, error) {
+	r := row{id: u.GetID()}
+	attrs := make(map[string]interface{}, len(u.GetAttributes()))
+	for k, v := range u.GetAttributes() {
+		attrs[k] = v
+	}
+	if v, _ := attrs[stores.UsernameAttribute].(string); v != "" {
+		r.username = sql.NullString{String: strings.ToLower(v), Valid: true}
+	}
+	if v, _ := attrs[stores.EmailAttribute].(string); v != "" {
+		r.email = sql.NullString{String: strings.ToLower(v), Valid: true}
+	}
+	r.passwordHash, _ = attrs[stores.PasswordHashAttribute].(string)
+	delete(attrs, stores.PasswordHashAttribute)
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		return r, fmt.Errorf("sqlstore: encode attributes of %q: %w", r.id, err)
+	}
+	r.attributes = b
+	return r, nil
+}
+
+// user decodes r. The password hash is not returned as an attribute.
+func (r row) user() (*stores.User, error) {
+	attrs := make(map[string]interface{})
+	if err := json.Unmarshal(r.attributes, &attrs); err != nil {
+		return nil, fmt.Errorf("sqlstore: decode attributes of %q: %w", r.id, err)
+	}
+	return &stores.User{ID: r.id, Attributes: attrs}, nil
+}
+
+const selectUser = `SELECT id, username, email, password_hash, attributes FROM auth_users`
+
+// queryUser runs a single-user query and scans its result.
+func (s *Store) queryUser(ctx context.Context, where string, args ...interface{}) (row, error) {
+	var r row
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(selectUser+" WHERE "+where), args...).
+		Scan(&r.id, &r.username, &r.email, &r.passwordHash, &r.attributes)
+	return r, err
+}
+
+// FindUserByID retrieves a user by ID.
+func (s *Store) FindUserByID(ctx context.Context, id string) (core.User, error) {
+	r, err := s.queryUser(ctx, "id = ?", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, core.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sqlstore: find user %q: %w", id, err)
+	}
+	return r.user()
+}
+
+// FindUserByCredentials looks a user up by the "id", "username", "email" or APIKeyCriterion
+// criteria; every one given must refer to the same user. If a "password" criterion is given, it
+// must be a string matching the user's bcrypt hash. Anything else returns core.ErrInvalidCredentials.
+func (s *Store) FindUserByCredentials(ctx context.Context, criteria map[string]interface{}) (core.User, error) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg string) {
+		conds = append(conds, cond)
+		args = append(args, arg)
+	}
+	if v, ok := criteria["id"].(string); ok {
+		add("id = ?", v)
+	}
+	if v, ok := criteria[stores.UsernameAttribute].(string); ok {
+		add("username = ?", strings.ToLower(v))
+	}
+	if v, ok := criteria[stores.EmailAttribute].(string); ok {
+		add("email = ?", strings.ToLower(v))
+	}
+	if v, ok := criteria[APIKeyCriterion].(string); ok {
+		add("id = (SELECT user_id FROM auth_api_keys WHERE key_hash = ?)", hashKey(v))
+	}
+	if len(conds) == 0 {
+		return nil, core.ErrInvalidCredentials
+	}
+	r, err := s.queryUser(ctx, strings.Join(conds, " AND "), args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, core.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sqlstore: find user by credentials: %w", err)
+	}
+	if p, given := criteria["password"]; given {
+		password, ok := p.(string)
+		if !ok || r.passwordHash == "" || bcrypt.CompareHashAndPassword([]byte(r.passwordHash), []byte(password)) != nil {
+			return nil, core.ErrInvalidCredentials
+		}
+	}
+	return r.user()
+}
+
+// checkUnique returns ErrUserExists if r's username or email belongs to a user other than r.
+func (s *Store) checkUnique(ctx context.Context, tx *sql.Tx, r row) error {
+	for _, c := range []struct {
+		column string
+		value  sql.NullString
+	}{{"username", r.username}, {"email", r.email}} {
+		if !c.value.Valid {
+			continue
+		}
+		var id string
+		err := tx.QueryRowContext(ctx, s.dialect.rebind("SELECT id FROM auth_users WHERE "+c.column+" = ? AND id <> ?"), c.value.String, r.id).Scan(&id)
+		if err == nil {
+			return fmt.Errorf("sqlstore: %s %q: %w", c.column, c.value.String, core.ErrUserExists)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+	return nil
+}
+
+// uniqueViolation reports whether err is a database's unique constraint violation, as when a
+// concurrent write takes an ID, username or email after checkUnique ran. Drivers share no error
+// type, so it matches the messages of SQLite, PostgreSQL (SQLSTATE 23505) and MySQL.
+func uniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, s := range []string{"UNIQUE constraint failed", "duplicate key value", "23505", "Duplicate entry"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// exists reports whether a user with id exists.
+func (s *Store) exists(ctx context.Context, tx *sql.Tx, id string) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, s.dialect.rebind("SELECT COUNT(*) FROM auth_users WHERE id = ?"), id).Scan(&n)
+	return n > 0, err
+}
+
+// write runs fn in a transaction with user converted to a row.
+func (s *Store) write(ctx context.Context, user core.User, fn func(tx *sql.Tx, r row) error) error {
+	r, err := toRow(user)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlstore: begin: %w", err)
+	}
+	defer tx.Rollback()
+	if err := fn(tx, r); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateUser adds user, failing with core.ErrUserExists if its ID, username or email is taken,
+// including by a concurrent CreateUser.
+// A stores.PasswordHashAttribute is stored in its own column rather than with the attributes.
+func (s *Store) CreateUser(ctx context.Context, user core.User) error {
+	return s.write(ctx, user, func(tx *sql.Tx, r row) error {
+		ok, err := s.exists(ctx, tx, r.id)
+		if err != nil {
+			return fmt.Errorf("sqlstore: create user %q: %w", r.id, err)
+		}
+		if ok {
+			return fmt.Errorf("sqlstore: id %q: %w", r.id, core.ErrUserExists)
+		}
+		if err := s.checkUnique(ctx, tx, r); err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		_, err = tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO auth_users
+			(id, username, email, password_hash, attributes, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`),
+			r.id, r.username, r.email, r.passwordHash, string(r.attributes), now, now)
+		if uniqueViolation(err) {
+			return fmt.Errorf("sqlstore: create user %q: %w: %w", r.id, core.ErrUserExists, err)
+		}
+		if err != nil {
+			return fmt.Errorf("sqlstore: create user %q: %w", r.id, err)
+		}
+		return nil
+	})
+}
+
+// UpdateUser replaces the user with the same ID, failing with core.ErrUserNotFound if there is
+// none or core.ErrUserExists if the new username or email belongs to someone else. The stored
+// password hash is kept unless user carries a stores.PasswordHashAttribute.
+func (s *Store) UpdateUser(ctx context.Context, user core.User) error {
+	return s.write(ctx, user, func(tx *sql.Tx, r row) error {
+		ok, err := s.exists(ctx, tx, r.id)
+		if err != nil {
+			return fmt.Errorf("sqlstore: update user %q: %w", r.id, err)
+		}
+		if !ok {
+			return core.ErrUserNotFound
+		}
+		if err := s.checkUnique(ctx, tx, r); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, s.dialect.rebind(`UPDATE auth_users SET
+			username = ?, email = ?, attributes = ?, updated_at = ?,
+			password_hash = CASE WHEN ? = '' THEN password_hash ELSE ? END
+			WHERE id = ?`),
+			r.username, r.email, string(r.attributes), time.Now().UTC(), r.passwordHash, r.passwordHash, r.id)
+		if uniqueViolation(err) {
+			return fmt.Errorf("sqlstore: update user %q: %w: %w", r.id, core.ErrUserExists, err)
+		}
+		if err != nil {
+			return fmt.Errorf("sqlstore: update user %q: %w", r.id, err)
+		}
+		return nil
+	})
+}
+
+// DeleteUser removes the user with id and their API keys, failing with core.ErrUserNotFound if
+// there is none.
+func (s *Store) DeleteUser(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlstore: begin: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind("DELETE FROM auth_api_keys WHERE user_id = ?"), id); err != nil {
+		return fmt.Errorf("sqlstore: delete user %q: %w", id, err)
+	}
+	res, err := tx.ExecContext(ctx, s.dialect.rebind("DELETE FROM auth_users WHERE id = ?"), id)
+	if err != nil {
+		return fmt.Errorf("sqlstore: delete user %q: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return core.ErrUserNotFound
+	}
+	return tx.Commit()
+}
+
+// ListUsers returns a page of users ordered by ID.
+func (s *Store) ListUsers(ctx context.Context, opts core.ListOptions) ([]core.User, error) {
+	query := selectUser + " WHERE id > ? ORDER BY id"
+	args := []interface{}{opts.After}
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+	}
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("sqlstore: list users: %w", err)
+	}
+	defer rows.Close()
+	var users []core.User
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.username, &r.email, &r.passwordHash, &r.attributes); err != nil {
+			return nil, fmt.Errorf("sqlstore: list users: %w", err)
+		}
+		u, err := r.user()
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlstore: list users: %w", err)
+	}
+	return users, nil
+}
+
+// hashKey returns the hex SHA-256 digest under which an API key is stored.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// AddAPIKey associates key with the user with userID, failing with core.ErrUserNotFound if there
+// is no such user. Only a hash of the key is stored.
+func (s *Store) AddAPIKey(ctx context.Context, key, userID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlstore: begin: %w", err)
+	}
+	defer tx.Rollback()
+	ok, err := s.exists(ctx, tx, userID)
+	if err != nil {
+		return fmt.Errorf("sqlstore: add api key: %w", err)
+	}
+	if !ok {
+		return core.ErrUserNotFound
+	}
+	_, err = tx.ExecContext(ctx, s.dialect.rebind("INSERT INTO auth_api_keys (key_hash, user_id, created_at) VALUES (?, ?, ?)"),
+		hashKey(key), userID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("sqlstore: add api key: %w", err)
+	}
+	return tx.Commit()
+}
+
+// RevokeAPIKey removes key so it can no longer authenticate, failing with
+// core.ErrInvalidCredentials if it is unknown.
+func (s *Store) RevokeAPIKey(ctx context.Context, key string) error {
+	var userID string
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind("DELETE FROM auth_api_keys WHERE key_hash = ? RETURNING user_id"), hashKey(key)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return core.ErrInvalidCredentials
+	}
+	if err != nil {
+		return fmt.Errorf("sqlstore: revoke api key: %w", err)
+	}
+	if s.Events != nil {
+		e := core.NewEvent(core.EventKeyRevoked, nil)
+		e.Strategy, e.UserID = "apikey", userID
+		s.Events.Emit(ctx, e)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package sqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"go-ez-auth/core"
+	"go-ez-auth/stores"
+	"go-ez-auth/stores/sqlstore"
+	"golang.org/x/crypto/bcrypt"
+	_ "modernc.org/sqlite"
+)
+
+func newStore(t *testing.T) *sqlstore.Store {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	db.SetMaxOpenConns(1) // every connection to :memory: is a separate database
+	t.Cleanup(func() { db.Close() })
+	s := sqlstore.New(db, sqlstore.SQLite)
+	if err := s.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return s
+}
+
+func TestMigrate_Idempotent(t *testing.T) {
+	db, _ := sql.Open("sqlite", ":memory:")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := sqlstore.Migrate(ctx, db, sqlstore.SQLite); err != nil {
+			t.Fatalf("Migrate run %d: %v", i+1, err)
+		}
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM auth_schema_migrations").Scan(&n); err != nil || n != len(sqlstore.SQLite.Migrations) {
+		t.Errorf("expected %d recorded migrations, got %d %v", len(sqlstore.SQLite.Migrations), n, err)
+	}
+}
+
+func TestStore_CRUD(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+	var _ core.UserWriter = s
+
+	alice := &stores.User{ID: "u1", Attributes: map[string]interface{}{"username": "Alice", "email": "alice@example.com", "role": "admin"}}
+	if err := s.CreateUser(ctx, alice); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := s.CreateUser(ctx, &stores.User{ID: "u1"}); !errors.Is(err, core.ErrUserExists) {
+		t.Errorf("expected ErrUserExists for duplicate ID, got %v", err)
+	}
+	if err := s.CreateUser(ctx, &stores.User{ID: "u2", Attributes: map[string]interface{}{"email": "ALICE@example.com"}}); !errors.Is(err, core.ErrUserExists) {
+		t.Errorf("expected ErrUserExists for duplicate email, got %v", err)
+	}
+
+	u, err := s.FindUserByID(ctx, "u1")
+	if err != nil || u.GetAttributes()["role"] != "admin" {
+		t.Fatalf("expected attributes to round-trip, got %v %v", u, err)
+	}
+	if u, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "ALICE"}); err != nil || u.GetID() != "u1" {
+		t.Errorf("expected case-insensitive lookup by username, got %v %v", u, err)
+	}
+	if _, err := s.FindUserByID(ctx, "nope"); err != core.ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+
+	if err := s.UpdateUser(ctx, &stores.User{ID: "u1", Attributes: map[string]interface{}{"username": "alicia"}}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "alice"}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected old username to be gone, got %v", err)
+	}
+	if err := s.UpdateUser(ctx, &stores.User{ID: "nope"}); err != core.ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+
+	if err := s.DeleteUser(ctx, "u1"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if err := s.DeleteUser(ctx, "u1"); err != core.ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestStore_UniqueViolation(t *testing.T) {
+	ctx := context.Background()
+	db, _ := sql.Open("sqlite", ":memory:")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	s := sqlstore.New(db, sqlstore.SQLite)
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	// A constraint the store does not check for itself stands in for a row inserted
+	// concurrently, between CreateUser's checks and its insert.
+	if _, err := db.Exec(`CREATE UNIQUE INDEX badge ON auth_users (json_extract(attributes, '$.badge'))`); err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+	if err := s.CreateUser(ctx, &stores.User{ID: "u1", Attributes: map[string]interface{}{"badge": "b1"}}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := s.CreateUser(ctx, &stores.User{ID: "u2", Attributes: map[string]interface{}{"badge": "b1"}}); !errors.Is(err, core.ErrUserExists) {
+		t.Errorf("expected ErrUserExists from the constraint on create, got %v", err)
+	}
+	s.CreateUser(ctx, &stores.User{ID: "u3", Attributes: map[string]interface{}{"badge": "b3"}})
+	if err := s.UpdateUser(ctx, &stores.User{ID: "u3", Attributes: map[string]interface{}{"badge": "b1"}}); !errors.Is(err, core.ErrUserExists) {
+		t.Errorf("expected ErrUserExists from the constraint on update, got %v", err)
+	}
+}
+
+func TestDialect_Rebind(t *testing.T) {
+	const query = "SELECT id FROM auth_users WHERE username = ? AND id <> ?"
+	if got := sqlstore.SQLite.Rebind(query); got != query {
+		t.Errorf("expected SQLite placeholders unchanged, got %q", got)
+	}
+	if got, want := sqlstore.Postgres.Rebind(query), "SELECT id FROM auth_users WHERE username = $1 AND id <> $2"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestStore_Password(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err := s.CreateUser(ctx, &stores.User{ID: "u1", Attributes: map[string]interface{}{"username": "bob", "password_hash": string(hash)}}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	u, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "bob", "password": "s3cret"})
+	if err != nil || u.GetID() != "u1" {
+		t.Fatalf("expected valid password to match, got %v %v", u, err)
+	}
+	if _, ok := u.GetAttributes()["password_hash"]; ok {
+		t.Error("expected the password hash not to be returned as an attribute")
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "bob", "password": "wrong"}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "bob", "password": []byte("s3cret")}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected non-string password to be rejected, got %v", err)
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "bob' OR '1'='1", "password": "s3cret"}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected injected username to be treated as data, got %v", err)
+	}
+
+	// Updating without a hash keeps the stored one.
+	if err := s.UpdateUser(ctx, u); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{"username": "bob", "password": "s3cret"}); err != nil {
+		t.Errorf("expected password to survive update, got %v", err)
+	}
+}
+
+func TestStore_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+	events := core.NewAuthenticator()
+	var revoked []core.Event
+	events.Subscribe(func(ctx context.Context, e core.Event) { revoked = append(revoked, e) })
+	s.Events = events
+	if err := s.CreateUser(ctx, &stores.User{ID: "u1"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := s.AddAPIKey(ctx, "k-123", "nope"); err != core.ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound for unknown user, got %v", err)
+	}
+	if err := s.AddAPIKey(ctx, "k-123", "u1"); err != nil {
+		t.Fatalf("AddAPIKey: %v", err)
+	}
+
+	if u, err := s.FindUserByCredentials(ctx, map[string]interface{}{sqlstore.APIKeyCriterion: "k-123"}); err != nil || u.GetID() != "u1" {
+		t.Errorf("expected key to resolve to u1, got %v %v", u, err)
+	}
+	if err := s.RevokeAPIKey(ctx, "k-123"); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if len(revoked) != 1 || revoked[0].Type != core.EventKeyRevoked || revoked[0].UserID != "u1" {
+		t.Errorf("expected one key revoked event for u1, got %+v", revoked)
+	}
+	if _, err := s.FindUserByCredentials(ctx, map[string]interface{}{sqlstore.APIKeyCriterion: "k-123"}); err != core.ErrInvalidCredentials {
+		t.Errorf("expected revoked key to fail, got %v", err)
+	}
+	if err := s.RevokeAPIKey(ctx, "k-123"); err != core.ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials for unknown key, got %v", err)
+	}
+}
+
+func TestStore_ListUsers(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+	for _, id := range []string{"c", "a", "b", "d"} {
+		if err := s.CreateUser(ctx, &stores.User{ID: id}); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	page, err := s.ListUsers(ctx, core.ListOptions{After: "a", Limit: 2})
+	if err != nil || len(page) != 2 || page[0].GetID() != "b" || page[1].GetID() != "c" {
+		t.Errorf("expected [b c], got %v %v", page, err)
+	}
+	all, _ := s.ListUsers(ctx, core.ListOptions{})
+	if len(all) != 4 {
+		t.Errorf("expected 4 users, got %d", len(all))
+	}
+}
@@ -0,0 +1,17 @@
+package stores
+
+// User is a plain core.User implementation for stores that hold user records directly.
+type User struct {
+	ID         string
+	Attributes map[string]interface{}
+}
+
+// GetID returns the user ID.
+func (u *User) GetID() string {
+	return u.ID
+}
+
+// GetAttributes returns the user's attributes.
+func (u *User) GetAttributes() map[string]interface{} {
+	return u.Attributes
+}
@@ -2,9 +2,13 @@ package apikey
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
 
 	"go-ez-auth/core"
+	"go-ez-auth/core/throttle"
 )
 
 // Config holds settings for the API key strategy.
@@ -13,6 +17,9 @@ type Config struct {
 	QueryParam string         // URL query parameter name for API key
 	CredKey    string         // credential key used in UserStore lookup
 	Store      core.UserStore // backend for user lookup
+	Realm      string         // optional realm of the ApiKey challenge
+	// Limiter, if set, locks out keys and client IPs after repeated unknown keys.
+	Limiter *throttle.Limiter
 }
 
 // Strategy implements core.Strategy for API key authentication.
@@ -39,13 +46,26 @@ func (s *Strategy) Name() string {
 	return "apikey"
 }
 
-// Setup is a no-op for API key strategy.
+// Setup validates that a Store is configured.
 func (s *Strategy) Setup() error {
+	if s.config.Store == nil {
+		return fmt.Errorf("apikey: Store is required: %w", core.ErrInvalidConfig)
+	}
 	return nil
 }
 
 // Authenticate extracts the API key from header or query param and validates it.
 func (s *Strategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	res, err := s.AuthenticateResult(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
+
+// AuthenticateResult validates the API key and identifies it in the result by a fingerprint
+// of the key, never the key itself.
+func (s *Strategy) AuthenticateResult(ctx context.Context, r *http.Request) (*core.AuthResult, error) {
 	// Try header
 	key := r.Header.Get(s.config.HeaderName)
 	// Fallback to query param
@@ -53,13 +73,41 @@ func (s *Strategy) Authenticate(ctx context.Context, r *http.Request) (core.User
 		key = r.URL.Query().Get(s.config.QueryParam)
 	}
 	if key == "" {
-		return nil, core.ErrUnauthorized
+		return nil, core.NoCredentialsError(s.Name())
+	}
+	keys := []string{throttle.APIKeyKey(s.Name(), key), throttle.IPKey(r)}
+	if err := s.config.Limiter.Check(ctx, keys...); err != nil {
+		return nil, core.NewAuthError(s.Name(), throttle.Reason(err), err)
 	}
 	// Lookup user by credential
 	criteria := map[string]interface{}{s.config.CredKey: key}
 	user, err := s.config.Store.FindUserByCredentials(ctx, criteria)
 	if err != nil {
-		return nil, core.ErrUnauthorized
+		reason := keyReason(err)
+		if reason != core.ReasonStoreError {
+			s.config.Limiter.Fail(ctx, keys...)
+		}
+		return nil, core.NewAuthError(s.Name(), reason, err)
+	}
+	s.config.Limiter.Reset(ctx, keys[0])
+	return &core.AuthResult{User: user, Strategy: s.Name(), Methods: []string{"apikey"}, CredentialID: Fingerprint(key)}, nil
+}
+
+// Challenge returns an ApiKey challenge naming the header the key is expected in.
+func (s *Strategy) Challenge(r *http.Request, err *core.AuthError) string {
+	return core.Challenge("ApiKey", "realm", s.config.Realm, "header", s.config.HeaderName)
+}
+
+// Fingerprint returns a short, non-reversible identifier for an API key, suitable for logs and audit records.
+func Fingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// keyReason maps a store lookup error to a core.Reason; a key the store does not know is ReasonUnknownKey.
+func keyReason(err error) core.Reason {
+	if reason := core.StoreReason(err); reason != core.ReasonInvalidCredentials {
+		return reason
 	}
-	return user, nil
+	return core.ReasonUnknownKey
 }
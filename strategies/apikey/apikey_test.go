@@ -2,10 +2,14 @@ package apikey_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"go-ez-auth/core"
+	"go-ez-auth/core/throttle"
 	"go-ez-auth/stores"
 	"go-ez-auth/strategies/apikey"
 )
@@ -19,7 +23,7 @@ func TestAuthenticate_NoKey(t *testing.T) {
 	s := apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(nil)})
 	req := httptest.NewRequest("GET", "/", nil)
 	_, err := s.Authenticate(context.Background(), req)
-	if err != core.ErrUnauthorized {
+	if !errors.Is(err, core.ErrUnauthorized) {
 		t.Errorf("expected ErrUnauthorized, got %v", err)
 	}
 }
@@ -48,3 +52,53 @@ func TestAuthenticate_QueryParam(t *testing.T) {
 		t.Fatalf("expected u2, got %v %v", user, err)
 	}
 }
+
+func TestAuthenticate_UnknownKey(t *testing.T) {
+	s := apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(nil)})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", "nope")
+	_, err := s.Authenticate(context.Background(), req)
+	if core.ReasonOf(err) != core.ReasonUnknownKey {
+		t.Errorf("expected unknown_key, got %v", err)
+	}
+	if !errors.Is(err, core.ErrInvalidCredentials) {
+		t.Errorf("expected store cause to be preserved, got %v", err)
+	}
+}
+
+func TestAuthenticate_NoKeyIsNoCredentials(t *testing.T) {
+	s := apikey.New(apikey.Config{Store: stores.NewAPIKeyStore(nil)})
+	_, err := s.Authenticate(context.Background(), httptest.NewRequest("GET", "/", nil))
+	if !errors.Is(err, core.ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+}
+
+func TestSetup_RequiresStore(t *testing.T) {
+	if err := apikey.New(apikey.Config{}).Setup(); !errors.Is(err, core.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+	if err := core.NewAuthenticator().Register(apikey.New(apikey.Config{})); err == nil {
+		t.Error("expected Register to reject a strategy without Store")
+	}
+}
+
+func TestAuthenticate_ThrottleByKey(t *testing.T) {
+	store := stores.NewAPIKeyStore(map[string]core.User{"sk_live_valid": dummyUser{"u1", "sk_live_valid"}})
+	limiter := throttle.New(throttle.Config{MaxFailures: 3, Window: time.Minute, Lockout: time.Minute})
+	s := apikey.New(apikey.Config{Store: store, Limiter: limiter})
+	ctx := context.Background()
+
+	// Bogus keys sharing the issuer prefix of a valid key, sent from another client.
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "203.0.113.7:5555"
+		req.Header.Set("X-API-Key", fmt.Sprintf("sk_live_bogus%d", i))
+		s.Authenticate(ctx, req)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-API-Key", "sk_live_valid")
+	if u, err := s.Authenticate(ctx, req); err != nil || u.GetID() != "u1" {
+		t.Errorf("expected the valid key not to be locked out, got %v %v", u, err)
+	}
+}
@@ -0,0 +1,308 @@
+// Package cache provides a decorator that caches the results of an expensive strategy, such as
+// a jwt strategy backed by a remote user store or an oauth2 token check. Entries are keyed by a
+// hash of the presented credential, expire after a TTL bounded by the credential's own expiry,
+// and are evicted least-recently-used once the cache is full. Failures can be cached briefly too.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-ez-auth/core"
+)
+
+// Config holds settings for the caching decorator.
+type Config struct {
+	Strategy core.Strategy // strategy whose results are cached
+
+	// Credential extracts the credential the cache is keyed by; requests for which it returns ""
+	// bypass the cache. Defaults to Header("Authorization").
+	Credential func(r *http.Request) string
+
+	TTL         time.Duration // lifetime of a success, capped at AuthResult.ExpiresAt; defaults to 1 minute
+	NegativeTTL time.Duration // lifetime of a rejected credential; defaults to 5 seconds, negative disables
+	MaxEntries  int           // maximum number of entries; defaults to 1024
+	Now         func() time.Time
+}
+
+// Header returns a Credential function reading the named request header.
+func Header(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// Cookie returns a Credential function reading the named cookie.
+func Cookie(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		c, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return c.Value
+	}
+}
+
+// entry is a cached outcome.
+type entry struct {
+	key     [sha256.Size]byte
+	res     *core.AuthResult // nil for a cached failure
+	err     error
+	userID  string
+	expires time.Time
+}
+
+// Strategy decorates a strategy with a cache. It has the same name as the decorated strategy,
+// so it can be registered in its place.
+type Strategy struct {
+	config  Config
+	mu      sync.Mutex
+	lru     *list.List // of *entry, most recently used first
+	entries map[[sha256.Size]byte]*list.Element
+	byUser  map[string]map[*list.Element]struct{}
+	gen     uint64 // incremented by InvalidateUser and Purge
+}
+
+// New creates a caching decorator with defaults.
+func New(config Config) *Strategy {
+	if config.Credential == nil {
+		config.Credential = Header("Authorization")
+	}
+	if config.TTL <= 0 {
+		config.TTL = time.Minute
+	}
+	if config.NegativeTTL == 0 {
+		config.NegativeTTL = 5 * time.Second
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 1024
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Strategy{
+		config:  config,
+		lru:     list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		byUser:  make(map[string]map[*list.Element]struct{}),
+	}
+}
+
+// Name returns the decorated strategy's name.
+func (s *Strategy) Name() string {
+	if s.config.Strategy == nil {
+		return "cache"
+	}
+	return s.config.Strategy.Name()
+}
+
+// Setup validates that a Strategy is configured and runs its Setup.
+func (s *Strategy) Setup() error {
+	if s.config.Strategy == nil {
+		return fmt.Errorf("cache: Strategy is required: %w", core.ErrInvalidConfig)
+	}
+	return s.config.Strategy.Setup()
+}
+
+// Close closes the decorated strategy if it implements io.Closer.
+func (s *Strategy) Close() error {
+	if c, ok := s.config.Strategy.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Challenge forwards to the decorated strategy if it implements core.Challenger.
+func (s *Strategy) Challenge(r *http.Request, err *core.AuthError) string {
+	if c, ok := s.config.Strategy.(core.Challenger); ok {
+		return c.Challenge(r, err)
+	}
+	return ""
+}
+
+// Authenticate returns the cached user for the request's credential, authenticating on a miss.
+func (s *Strategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	res, err := s.AuthenticateResult(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
+
+// AuthenticateResult returns the cached result for the request's credential, authenticating
+// on a miss. Only successes and rejected credentials are cached; missing credentials, lockouts
+// and store or upstream errors always reach the decorated strategy.
+func (s *Strategy) AuthenticateResult(ctx context.Context, r *http.Request) (*core.AuthResult, error) {
+	cred := s.config.Credential(r)
+	if cred == "" {
+		return s.authenticate(ctx, r)
+	}
+	key := sha256.Sum256([]byte(cred))
+	res, err, ok, gen := s.get(key)
+	if ok {
+		return res, err
+	}
+	res, err = s.authenticate(ctx, r)
+	s.put(key, gen, res, err)
+	if err != nil {
+		return nil, err
+	}
+	return copyResult(res), nil
+}
+
+// authenticate runs the decorated strategy.
+func (s *Strategy) authenticate(ctx context.Context, r *http.Request) (*core.AuthResult, error) {
+	if rs, ok := s.config.Strategy.(core.ResultStrategy); ok {
+		return rs.AuthenticateResult(ctx, r)
+	}
+	user, err := s.config.Strategy.Authenticate(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return &core.AuthResult{User: user}, nil
+}
+
+// get returns the live entry for key, if any. On a miss it returns the current generation,
+// to be passed to put with the outcome of authenticating key.
+func (s *Strategy) get(key [sha256.Size]byte) (*core.AuthResult, error, bool, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, nil, false, s.gen
+	}
+	e := el.Value.(*entry)
+	if !s.config.Now().Before(e.expires) {
+		s.remove(el)
+		return nil, nil, false, s.gen
+	}
+	s.lru.MoveToFront(el)
+	if e.res == nil {
+		return nil, e.err, true, s.gen
+	}
+	return copyResult(e.res), nil, true, s.gen
+}
+
+// put caches the outcome of authenticating key, if it is cacheable. The outcome is dropped if
+// the cache was invalidated since get returned gen, as it may predate the revocation.
+func (s *Strategy) put(key [sha256.Size]byte, gen uint64, res *core.AuthResult, err error) {
+	now := s.config.Now()
+	e := &entry{key: key, res: res, err: err}
+	switch {
+	case err == nil:
+		e.userID = res.User.GetID()
+		e.expires = now.Add(s.config.TTL)
+		if !res.ExpiresAt.IsZero() && res.ExpiresAt.Before(e.expires) {
+			e.expires = res.ExpiresAt
+		}
+	case s.config.NegativeTTL > 0 && rejected(err):
+		e.expires = now.Add(s.config.NegativeTTL)
+	default:
+		return
+	}
+	if !now.Before(e.expires) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.gen != gen {
+		return
+	}
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	el := s.lru.PushFront(e)
+	s.entries[key] = el
+	if e.userID != "" {
+		if s.byUser[e.userID] == nil {
+			s.byUser[e.userID] = make(map[*list.Element]struct{})
+		}
+		s.byUser[e.userID][el] = struct{}{}
+	}
+	for s.lru.Len() > s.config.MaxEntries {
+		s.remove(s.lru.Back())
+	}
+}
+
+// rejected reports whether err is a definitive rejection of a presented credential, as opposed
+// to an absent credential, a lockout, or a transient backend failure.
+func rejected(err error) bool {
+	var ae *core.AuthError
+	if !errors.As(err, &ae) {
+		return errors.Is(err, core.ErrInvalidCredentials) || errors.Is(err, core.ErrUserNotFound)
+	}
+	switch ae.Reason {
+	case core.ReasonMissingCredentials, core.ReasonThrottled, core.ReasonStoreError, core.ReasonUpstreamError:
+		return false
+	}
+	return true
+}
+
+// remove deletes el from the cache. s.mu must be held.
+func (s *Strategy) remove(el *list.Element) {
+	e := s.lru.Remove(el).(*entry)
+	delete(s.entries, e.key)
+	if users := s.byUser[e.userID]; users != nil {
+		delete(users, el)
+		if len(users) == 0 {
+			delete(s.byUser, e.userID)
+		}
+	}
+}
+
+// InvalidateUser removes every cached success for the user, e.g. after revoking their access.
+// Authentications already in flight are not cached when they complete.
+func (s *Strategy) InvalidateUser(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gen++
+	for el := range s.byUser[userID] {
+		s.remove(el)
+	}
+}
+
+// Purge removes every entry. Authentications already in flight are not cached when they complete.
+func (s *Strategy) Purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gen++
+	s.lru.Init()
+	s.entries = make(map[[sha256.Size]byte]*list.Element)
+	s.byUser = make(map[string]map[*list.Element]struct{})
+}
+
+// Len returns the number of cached entries, including expired ones not yet evicted.
+func (s *Strategy) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// HandleEvent invalidates the user of core.EventKeyRevoked and core.EventLogout events, and
+// purges everything on core.EventStoreReloaded. Pass it to Authenticator.Subscribe so that
+// revocations take effect immediately.
+func (s *Strategy) HandleEvent(ctx context.Context, e core.Event) {
+	switch e.Type {
+	case core.EventKeyRevoked, core.EventLogout:
+		if e.UserID != "" {
+			s.InvalidateUser(e.UserID)
+		}
+	case core.EventStoreReloaded:
+		s.Purge()
+	}
+}
+
+// copyResult returns a copy of res that callers may modify.
+func copyResult(res *core.AuthResult) *core.AuthResult {
+	c := *res
+	c.Methods = append([]string(nil), res.Methods...)
+	return &c
+}
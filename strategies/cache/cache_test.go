@@ -0,0 +1,183 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-ez-auth/core"
+	"go-ez-auth/stores"
+	"go-ez-auth/strategies/cache"
+)
+
+// backend is a strategy that counts calls and accepts "Bearer <user>" for known users.
+type backend struct {
+	calls   int
+	users   map[string]bool
+	expires time.Time
+	err     error  // returned instead of looking up the user, if set
+	during  func() // called while authenticating, if set
+}
+
+func (b *backend) Name() string { return "remote" }
+func (b *backend) Setup() error { return nil }
+func (b *backend) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	res, err := b.AuthenticateResult(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
+func (b *backend) AuthenticateResult(ctx context.Context, r *http.Request) (*core.AuthResult, error) {
+	b.calls++
+	if b.during != nil {
+		b.during()
+	}
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return nil, core.NoCredentialsError("remote")
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	id := auth[len("Bearer "):]
+	if !b.users[id] {
+		return nil, core.NewAuthError("remote", core.ReasonInvalidToken, nil)
+	}
+	return &core.AuthResult{User: &stores.User{ID: id}, ExpiresAt: b.expires}, nil
+}
+
+func request(token string) *http.Request {
+	req := httptest.NewRequest("GET", "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestCache_HitsAndTTL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := &backend{users: map[string]bool{"u1": true}, expires: now.Add(30 * time.Second)}
+	c := cache.New(cache.Config{Strategy: b, TTL: time.Minute, Now: func() time.Time { return now }})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if u, err := c.Authenticate(ctx, request("u1")); err != nil || u.GetID() != "u1" {
+			t.Fatalf("expected u1, got %v %v", u, err)
+		}
+	}
+	if b.calls != 1 {
+		t.Errorf("expected one backend call, got %d", b.calls)
+	}
+
+	// The entry expires with the token, before the TTL.
+	now = now.Add(31 * time.Second)
+	c.Authenticate(ctx, request("u1"))
+	if b.calls != 2 {
+		t.Errorf("expected entry to expire with the token, got %d calls", b.calls)
+	}
+
+	// Missing credentials bypass the cache.
+	c.Authenticate(ctx, request(""))
+	c.Authenticate(ctx, request(""))
+	if b.calls != 4 {
+		t.Errorf("expected missing credentials not to be cached, got %d calls", b.calls)
+	}
+}
+
+func TestCache_NegativeCaching(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := &backend{users: map[string]bool{}}
+	c := cache.New(cache.Config{Strategy: b, NegativeTTL: 5 * time.Second, Now: func() time.Time { return now }})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Authenticate(ctx, request("bad")); core.ReasonOf(err) != core.ReasonInvalidToken {
+			t.Fatalf("expected invalid_token, got %v", err)
+		}
+	}
+	if b.calls != 1 {
+		t.Errorf("expected failure to be cached, got %d calls", b.calls)
+	}
+	now = now.Add(6 * time.Second)
+	c.Authenticate(ctx, request("bad"))
+	if b.calls != 2 {
+		t.Errorf("expected negative entry to expire, got %d calls", b.calls)
+	}
+
+	// Backend errors are never cached.
+	b.err = core.NewAuthError("remote", core.ReasonUpstreamError, errors.New("timeout"))
+	c.Authenticate(ctx, request("other"))
+	c.Authenticate(ctx, request("other"))
+	if b.calls != 4 {
+		t.Errorf("expected upstream errors not to be cached, got %d calls", b.calls)
+	}
+}
+
+func TestCache_LRUAndInvalidation(t *testing.T) {
+	b := &backend{users: map[string]bool{"u1": true, "u2": true, "u3": true}}
+	c := cache.New(cache.Config{Strategy: b, MaxEntries: 2})
+	ctx := context.Background()
+
+	c.Authenticate(ctx, request("u1"))
+	c.Authenticate(ctx, request("u2"))
+	c.Authenticate(ctx, request("u1")) // u1 is now most recently used
+	c.Authenticate(ctx, request("u3")) // evicts u2
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Len())
+	}
+	calls := b.calls
+	c.Authenticate(ctx, request("u1"))
+	if b.calls != calls {
+		t.Error("expected u1 to still be cached")
+	}
+
+	c.HandleEvent(ctx, core.Event{Type: core.EventKeyRevoked, UserID: "u1"})
+	c.Authenticate(ctx, request("u1"))
+	if b.calls != calls+1 {
+		t.Error("expected u1 to be invalidated")
+	}
+
+	c.HandleEvent(ctx, core.Event{Type: core.EventStoreReloaded})
+	if c.Len() != 0 {
+		t.Errorf("expected a store reload to purge the cache, got %d entries", c.Len())
+	}
+}
+
+func TestCache_InvalidationDuringAuthentication(t *testing.T) {
+	b := &backend{users: map[string]bool{"u1": true}}
+	c := cache.New(cache.Config{Strategy: b})
+	ctx := context.Background()
+
+	// A revocation racing with a miss must not be undone by caching the stale result.
+	b.during = func() { c.HandleEvent(ctx, core.Event{Type: core.EventKeyRevoked, UserID: "u1"}) }
+	if u, err := c.Authenticate(ctx, request("u1")); err != nil || u.GetID() != "u1" {
+		t.Fatalf("expected u1, got %v %v", u, err)
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected the result not to be cached, got %d entries", c.Len())
+	}
+	b.during = c.Purge
+	c.Authenticate(ctx, request("u1"))
+	if c.Len() != 0 {
+		t.Errorf("expected the result not to be cached across a purge, got %d entries", c.Len())
+	}
+
+	b.during = nil
+	c.Authenticate(ctx, request("u1"))
+	if c.Len() != 1 {
+		t.Errorf("expected later results to be cached, got %d entries", c.Len())
+	}
+}
+
+func TestCache_Setup(t *testing.T) {
+	if err := cache.New(cache.Config{}).Setup(); !errors.Is(err, core.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+	if name := cache.New(cache.Config{Strategy: &backend{}}).Name(); name != "remote" {
+		t.Errorf("expected decorated name, got %q", name)
+	}
+}
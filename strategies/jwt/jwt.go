@@ -3,8 +3,10 @@ package jwt
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"go-ez-auth/core"
 	jwtLib "github.com/golang-jwt/jwt/v5"
@@ -17,6 +19,8 @@ type Config struct {
 	Issuer        string
 	Audience      string
 	Store         core.UserStore
+	Events        core.EventEmitter // optional; receives token issued events
+	Realm         string            // optional realm of the Bearer challenge
 }
 
 // Strategy implements the core.Strategy interface for JWT.
@@ -37,37 +41,81 @@ func (s *Strategy) Name() string {
 	return "jwt"
 }
 
-// Setup is a no-op for JWT strategy.
+// Setup validates the signing configuration.
 func (s *Strategy) Setup() error {
+	if len(s.config.SigningKey) == 0 {
+		return fmt.Errorf("jwt: SigningKey is required: %w", core.ErrInvalidConfig)
+	}
+	method := jwtLib.GetSigningMethod(s.config.SigningMethod)
+	if method == nil {
+		return fmt.Errorf("jwt: unknown SigningMethod %q: %w", s.config.SigningMethod, core.ErrInvalidConfig)
+	}
+	if _, ok := method.(*jwtLib.SigningMethodHMAC); !ok {
+		return fmt.Errorf("jwt: SigningMethod %q is not an HMAC method; SigningKey only supports HMAC: %w", s.config.SigningMethod, core.ErrInvalidConfig)
+	}
 	return nil
 }
 
+// Challenge returns an RFC 6750 Bearer challenge, with error="invalid_token" when a presented
+// token was rejected.
+func (s *Strategy) Challenge(r *http.Request, err *core.AuthError) string {
+	params := []string{"realm", s.config.Realm}
+	if err != nil {
+		switch err.Reason {
+		case core.ReasonExpired:
+			params = append(params, "error", "invalid_token", "error_description", "the access token expired")
+		case core.ReasonInvalidToken, core.ReasonBadSignature, core.ReasonUnknownKey, core.ReasonInvalidCredentials:
+			params = append(params, "error", "invalid_token")
+		}
+	}
+	return core.Challenge("Bearer", params...)
+}
+
+// tokenClaims are the registered claims plus the OIDC claims describing how the user authenticated.
+type tokenClaims struct {
+	jwtLib.RegisteredClaims
+	AMR      []string            `json:"amr,omitempty"`
+	AuthTime *jwtLib.NumericDate `json:"auth_time,omitempty"`
+}
+
 // Authenticate extracts and validates a JWT from the Authorization header.
 func (s *Strategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	res, err := s.AuthenticateResult(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
+
+// AuthenticateResult validates the bearer token and reports its "amr", "auth_time" (or "iat"),
+// expiry and "jti" claims in the result.
+func (s *Strategy) AuthenticateResult(ctx context.Context, r *http.Request) (*core.AuthResult, error) {
 	auth := r.Header.Get("Authorization")
 	if auth == "" {
-		return nil, core.ErrUnauthorized
+		return nil, core.NoCredentialsError(s.Name())
 	}
 	parts := strings.SplitN(auth, " ", 2)
 	if len(parts) != 2 || parts[0] != "Bearer" {
-		return nil, core.ErrUnauthorized
+		// Another scheme (e.g. Basic) belongs to a different strategy.
+		return nil, core.NoCredentialsError(s.Name())
 	}
 	tokenString := parts[1]
-
-	claims := &jwtLib.RegisteredClaims{}
+	claims := &tokenClaims{}
 	token, err := jwtLib.ParseWithClaims(tokenString, claims, func(token *jwtLib.Token) (interface{}, error) {
 		if token.Method.Alg() != s.config.SigningMethod {
-			return nil, errors.New("unexpected signing method")
+			return nil, errUnexpectedSigningMethod
 		}
 		return s.config.SigningKey, nil
 	})
-	if err != nil || !token.Valid {
-		return nil, core.ErrUnauthorized
+	if err != nil {
+		return nil, core.NewAuthError(s.Name(), parseReason(err), err)
+	}
+	if !token.Valid {
+		return nil, core.NewAuthError(s.Name(), core.ReasonInvalidToken, nil)
 	}
-
 	// Validate issuer
 	if s.config.Issuer != "" && claims.Issuer != s.config.Issuer {
-		return nil, core.ErrUnauthorized
+		return nil, core.NewAuthError(s.Name(), core.ReasonInvalidToken, fmt.Errorf("unexpected issuer %q", claims.Issuer))
 	}
 	// Validate audience
 	if s.config.Audience != "" {
@@ -79,21 +127,30 @@ func (s *Strategy) Authenticate(ctx context.Context, r *http.Request) (core.User
 			}
 		}
 		if !found {
-			return nil, core.ErrUnauthorized
+			return nil, core.NewAuthError(s.Name(), core.ReasonInvalidToken, fmt.Errorf("audience %q not accepted", claims.Audience))
 		}
 	}
-
 	userID := claims.Subject
 	if userID == "" {
-		return nil, core.ErrUnauthorized
+		return nil, core.NewAuthError(s.Name(), core.ReasonInvalidToken, errors.New("token has no subject"))
+	}
+	res := &core.AuthResult{Strategy: s.Name(), Methods: claims.AMR, CredentialID: claims.ID}
+	if claims.AuthTime != nil {
+		res.AuthTime = claims.AuthTime.Time
+	} else if claims.IssuedAt != nil {
+		res.AuthTime = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		res.ExpiresAt = claims.ExpiresAt.Time
 	}
 	// If a store is provided, lookup the user
 	if s.config.Store != nil {
 		user, err := s.config.Store.FindUserByID(ctx, userID)
 		if err != nil {
-			return nil, core.ErrUnauthorized
+			return nil, core.NewAuthError(s.Name(), core.StoreReason(err), err)
 		}
-		return user, nil
+		res.User = user
+		return res, nil
 	}
 	// Otherwise return a simple user with claims as attributes
 	attrs := map[string]interface{}{ // include basic claims
@@ -101,7 +158,58 @@ func (s *Strategy) Authenticate(ctx context.Context, r *http.Request) (core.User
 		"audience": claims.Audience,
 		"expires":  claims.ExpiresAt,
 	}
-	return &jwtUser{id: userID, attributes: attrs}, nil
+	res.User = &jwtUser{id: userID, attributes: attrs}
+	return res, nil
+}
+
+// Issue signs a token for user that expires after ttl, using the configured issuer and audience.
+// r, which may be nil, is used to attribute the token issued event to a client.
+func (s *Strategy) Issue(r *http.Request, user core.User, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwtLib.RegisteredClaims{
+		Subject:   user.GetID(),
+		Issuer:    s.config.Issuer,
+		IssuedAt:  jwtLib.NewNumericDate(now),
+		ExpiresAt: jwtLib.NewNumericDate(now.Add(ttl)),
+	}
+	if s.config.Audience != "" {
+		claims.Audience = jwtLib.ClaimStrings{s.config.Audience}
+	}
+	method := jwtLib.GetSigningMethod(s.config.SigningMethod)
+	if method == nil {
+		return "", fmt.Errorf("jwt: unknown SigningMethod %q: %w", s.config.SigningMethod, core.ErrInvalidConfig)
+	}
+	token, err := jwtLib.NewWithClaims(method, claims).SignedString(s.config.SigningKey)
+	if err != nil {
+		return "", err
+	}
+	if s.config.Events != nil {
+		ctx := context.Background()
+		if r != nil {
+			ctx = r.Context()
+		}
+		e := core.NewEvent(core.EventTokenIssued, r)
+		e.Strategy, e.UserID = s.Name(), user.GetID()
+		s.config.Events.Emit(ctx, e)
+	}
+	return token, nil
+}
+
+// errUnexpectedSigningMethod is returned by the key function when a token's alg does not match Config.SigningMethod.
+var errUnexpectedSigningMethod = errors.New("unexpected signing method")
+
+// parseReason maps a jwt parse error to a core.Reason.
+func parseReason(err error) core.Reason {
+	switch {
+	case errors.Is(err, jwtLib.ErrTokenExpired):
+		return core.ReasonExpired
+	case errors.Is(err, jwtLib.ErrTokenSignatureInvalid):
+		return core.ReasonBadSignature
+	case errors.Is(err, errUnexpectedSigningMethod):
+		return core.ReasonUnknownKey
+	default:
+		return core.ReasonInvalidToken
+	}
 }
 
 // jwtUser is a simple User implementation for JWTStrategy.
@@ -2,6 +2,7 @@ package jwt_test
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"testing"
 	"time"
@@ -20,7 +21,7 @@ func TestAuthenticate_NoHeader(t *testing.T) {
 	s := jwt.New(jwt.Config{SigningKey: []byte("secret")})
 	req, _ := http.NewRequest("GET", "/", nil)
 	_, err := s.Authenticate(context.Background(), req)
-	if err != core.ErrUnauthorized {
+	if !errors.Is(err, core.ErrUnauthorized) {
 		t.Errorf("expected ErrUnauthorized, got %v", err)
 	}
 }
@@ -30,7 +31,7 @@ func TestAuthenticate_InvalidToken(t *testing.T) {
 	req, _ := http.NewRequest("GET", "/", nil)
 	req.Header.Set("Authorization", "Bearer invalid.token.here")
 	_, err := s.Authenticate(context.Background(), req)
-	if err != core.ErrUnauthorized {
+	if !errors.Is(err, core.ErrUnauthorized) {
 		t.Errorf("expected ErrUnauthorized for invalid token, got %v", err)
 	}
 }
@@ -116,3 +117,135 @@ func TestAuthenticate_ValidToken_WithStore(t *testing.T) {
 		t.Errorf("expected user456, got %s", user.GetID())
 	}
 }
+
+func TestAuthenticate_ErrorReasons(t *testing.T) {
+	key := []byte("secret")
+	sign := func(k []byte, exp time.Time) string {
+		claims := jwtLib.RegisteredClaims{Subject: "u1", ExpiresAt: jwtLib.NewNumericDate(exp)}
+		str, err := jwtLib.NewWithClaims(jwtLib.SigningMethodHS256, claims).SignedString(k)
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return str
+	}
+	cases := []struct {
+		name   string
+		header string
+		want   core.Reason
+	}{
+		{"missing", "", core.ReasonMissingCredentials},
+		{"malformed", "Bearer invalid.token.here", core.ReasonInvalidToken},
+		{"expired", "Bearer " + sign(key, time.Now().Add(-time.Hour)), core.ReasonExpired},
+		{"bad signature", "Bearer " + sign([]byte("other"), time.Now().Add(time.Hour)), core.ReasonBadSignature},
+	}
+	s := jwt.New(jwt.Config{SigningKey: key})
+	for _, tc := range cases {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		_, err := s.Authenticate(context.Background(), req)
+		var authErr *core.AuthError
+		if !errors.As(err, &authErr) {
+			t.Fatalf("%s: expected *core.AuthError, got %T", tc.name, err)
+		}
+		if authErr.Strategy != "jwt" || authErr.Reason != tc.want {
+			t.Errorf("%s: expected jwt/%s, got %s/%s", tc.name, tc.want, authErr.Strategy, authErr.Reason)
+		}
+	}
+}
+
+func TestSetup_Validation(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  jwt.Config
+		ok   bool
+	}{
+		{"valid", jwt.Config{SigningKey: []byte("secret")}, true},
+		{"missing key", jwt.Config{}, false},
+		{"unknown method", jwt.Config{SigningKey: []byte("secret"), SigningMethod: "XX999"}, false},
+		{"non-hmac method", jwt.Config{SigningKey: []byte("secret"), SigningMethod: "RS256"}, false},
+	}
+	for _, tc := range cases {
+		err := jwt.New(tc.cfg).Setup()
+		if tc.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if !tc.ok && !errors.Is(err, core.ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestIssue_RoundTrip(t *testing.T) {
+	events := core.NewAuthenticator()
+	var issued []core.Event
+	events.Subscribe(func(ctx context.Context, e core.Event) { issued = append(issued, e) })
+	s := jwt.New(jwt.Config{SigningKey: []byte("secret"), Issuer: "iss", Audience: "aud", Events: events})
+
+	token, err := s.Issue(nil, dummyUser{"u9"}, time.Minute)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	user, err := s.Authenticate(context.Background(), req)
+	if err != nil || user.GetID() != "u9" {
+		t.Fatalf("expected u9, got %v %v", user, err)
+	}
+	if len(issued) != 1 || issued[0].Type != core.EventTokenIssued || issued[0].UserID != "u9" {
+		t.Errorf("expected token issued event, got %+v", issued)
+	}
+}
+
+func TestAuthenticateResult_Claims(t *testing.T) {
+	key := []byte("secret")
+	authTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	claims := jwtLib.MapClaims{
+		"sub":       "u1",
+		"jti":       "token-1",
+		"amr":       []string{"pwd", "otp"},
+		"auth_time": authTime.Unix(),
+		"exp":       expires.Unix(),
+	}
+	tokenString, err := jwtLib.NewWithClaims(jwtLib.SigningMethodHS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+
+	res, err := jwt.New(jwt.Config{SigningKey: key}).AuthenticateResult(context.Background(), req)
+	if err != nil {
+		t.Fatalf("AuthenticateResult: %v", err)
+	}
+	if res.User.GetID() != "u1" || res.CredentialID != "token-1" {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if len(res.Methods) != 2 || res.Methods[1] != "otp" {
+		t.Errorf("expected amr [pwd otp], got %v", res.Methods)
+	}
+	if !res.AuthTime.Equal(authTime) || !res.ExpiresAt.Equal(expires) {
+		t.Errorf("unexpected times auth=%v exp=%v", res.AuthTime, res.ExpiresAt)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	s := jwt.New(jwt.Config{SigningKey: []byte("secret"), Realm: "api"})
+	req, _ := http.NewRequest("GET", "/", nil)
+	cases := []struct {
+		err  *core.AuthError
+		want string
+	}{
+		{nil, `Bearer realm="api"`},
+		{core.NoCredentialsError("jwt"), `Bearer realm="api"`},
+		{core.NewAuthError("jwt", core.ReasonBadSignature, nil), `Bearer realm="api", error="invalid_token"`},
+		{core.NewAuthError("jwt", core.ReasonExpired, nil), `Bearer realm="api", error="invalid_token", error_description="the access token expired"`},
+	}
+	for _, tc := range cases {
+		if got := s.Challenge(req, tc.err); got != tc.want {
+			t.Errorf("expected %s, got %s", tc.want, got)
+		}
+	}
+}
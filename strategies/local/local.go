@@ -2,15 +2,20 @@ package local
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"go-ez-auth/core"
+	"go-ez-auth/core/throttle"
 )
 
 // Config holds settings for the local username/password strategy.
 // Users are authenticated via Basic Auth and validated against the UserStore.
 type Config struct {
 	UserStore core.UserStore
+	Realm     string // realm of the Basic challenge; defaults to "restricted"
+	// Limiter, if set, locks out usernames and client IPs after repeated failed logins.
+	Limiter *throttle.Limiter
 }
 
 // Strategy implements core.Strategy for local auth.
@@ -20,6 +25,9 @@ type Strategy struct {
 
 // New creates a new local auth strategy.
 func New(config Config) *Strategy {
+	if config.Realm == "" {
+		config.Realm = "restricted"
+	}
 	return &Strategy{config: config}
 }
 
@@ -28,21 +36,47 @@ func (s *Strategy) Name() string {
 	return "local"
 }
 
-// Setup is a no-op for the local strategy.
+// Setup validates that a UserStore is configured.
 func (s *Strategy) Setup() error {
+	if s.config.UserStore == nil {
+		return fmt.Errorf("local: UserStore is required: %w", core.ErrInvalidConfig)
+	}
 	return nil
 }
 
 // Authenticate parses Basic Auth credentials and delegates to UserStore.FindUserByCredentials.
 func (s *Strategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	res, err := s.AuthenticateResult(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
+
+// AuthenticateResult validates Basic Auth credentials and reports the "pwd" method.
+func (s *Strategy) AuthenticateResult(ctx context.Context, r *http.Request) (*core.AuthResult, error) {
 	username, password, ok := r.BasicAuth()
 	if !ok {
-		return nil, core.ErrUnauthorized
+		return nil, core.NoCredentialsError(s.Name())
+	}
+	keys := []string{throttle.UserKey(s.Name(), username), throttle.IPKey(r)}
+	if err := s.config.Limiter.Check(ctx, keys...); err != nil {
+		return nil, core.NewAuthError(s.Name(), throttle.Reason(err), err)
 	}
 	// Delegate credential lookup with criteria map
 	user, err := s.config.UserStore.FindUserByCredentials(ctx, map[string]interface{}{"username": username, "password": password})
 	if err != nil {
-		return nil, core.ErrUnauthorized
+		reason := core.StoreReason(err)
+		if reason == core.ReasonInvalidCredentials {
+			s.config.Limiter.Fail(ctx, keys...)
+		}
+		return nil, core.NewAuthError(s.Name(), reason, err)
 	}
-	return user, nil
+	s.config.Limiter.Reset(ctx, keys[0])
+	return &core.AuthResult{User: user, Strategy: s.Name(), Methods: []string{"pwd"}}, nil
+}
+
+// Challenge returns a Basic challenge so that browsers prompt for credentials.
+func (s *Strategy) Challenge(r *http.Request, err *core.AuthError) string {
+	return core.Challenge("Basic", "realm", s.config.Realm, "charset", "UTF-8")
 }
@@ -2,10 +2,12 @@ package local_test
 
 import (
 	"context"
+	"errors"
 	"net/http/httptest"
 	"testing"
 
 	"go-ez-auth/core"
+	"go-ez-auth/core/throttle"
 	"go-ez-auth/strategies/local"
 
 	"golang.org/x/crypto/bcrypt"
@@ -64,7 +66,36 @@ func TestLocalStrategy_Authenticate_Unauthorized(t *testing.T) {
 	req.SetBasicAuth("user1", "wrongpass")
 
 	_, err := strat.Authenticate(context.Background(), req)
-	if err != core.ErrUnauthorized {
+	if !errors.Is(err, core.ErrUnauthorized) {
 		t.Errorf("expected ErrUnauthorized, got %v", err)
 	}
 }
+
+func TestLocalStrategy_Setup(t *testing.T) {
+	if err := local.New(local.Config{}).Setup(); !errors.Is(err, core.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestLocalStrategy_Throttled(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	limiter := throttle.New(throttle.Config{MaxFailures: 2})
+	strat := local.New(local.Config{UserStore: dummyStore{hash: string(hash), user: dummyUser{"u1"}}, Limiter: limiter})
+
+	login := func(password string) error {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.SetBasicAuth("user1", password)
+		_, err := strat.Authenticate(context.Background(), req)
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := login("wrong"); core.ReasonOf(err) != core.ReasonInvalidCredentials {
+			t.Fatalf("attempt %d: expected invalid_credentials, got %v", i, err)
+		}
+	}
+	// Even the correct password is refused while locked out.
+	err := login("secret123")
+	if core.ReasonOf(err) != core.ReasonThrottled || !errors.Is(err, core.ErrThrottled) {
+		t.Errorf("expected throttled, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package oauth2
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"golang.org/x/oauth2"
@@ -34,45 +35,63 @@ func (s *Strategy) Name() string {
 	return "oauth2"
 }
 
-// Setup is a no-op for OAuth2 strategy.
+// Setup validates that the OAuth2 client, userinfo endpoint and extractor are configured.
 func (s *Strategy) Setup() error {
+	switch {
+	case s.config.OAuth2Config == nil:
+		return fmt.Errorf("oauth2: OAuth2Config is required: %w", core.ErrInvalidConfig)
+	case s.config.OAuth2Config.Endpoint.TokenURL == "":
+		return fmt.Errorf("oauth2: OAuth2Config.Endpoint.TokenURL is required: %w", core.ErrInvalidConfig)
+	case s.config.UserInfoURL == "":
+		return fmt.Errorf("oauth2: UserInfoURL is required: %w", core.ErrInvalidConfig)
+	case s.config.ExtractUser == nil:
+		return fmt.Errorf("oauth2: ExtractUser is required: %w", core.ErrInvalidConfig)
+	}
 	return nil
 }
 
 // Authenticate handles OAuth2 callback: exchanges code, fetches userinfo, and returns core.User.
 func (s *Strategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	res, err := s.AuthenticateResult(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
+
+// AuthenticateResult performs the callback flow and reports the access token's expiry.
+func (s *Strategy) AuthenticateResult(ctx context.Context, r *http.Request) (*core.AuthResult, error) {
 	code := r.URL.Query().Get("code")
 	if code == "" {
-		return nil, core.ErrUnauthorized
+		return nil, core.NoCredentialsError(s.Name())
 	}
-
 	// Exchange code for token
 	tok, err := s.config.OAuth2Config.Exchange(ctx, code)
 	if err != nil {
-		return nil, core.ErrUnauthorized
+		return nil, core.NewAuthError(s.Name(), core.ReasonInvalidCredentials, err)
 	}
-
 	// Fetch user info
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.config.UserInfoURL, nil)
 	if err != nil {
-		return nil, core.ErrUnauthorized
+		return nil, core.NewAuthError(s.Name(), core.ReasonUpstreamError, err)
 	}
 	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
 	resp, err := http.DefaultClient.Do(req)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return nil, core.ErrUnauthorized
+	if err != nil {
+		return nil, core.NewAuthError(s.Name(), core.ReasonUpstreamError, err)
 	}
 	defer resp.Body.Close()
-
+	if resp.StatusCode != http.StatusOK {
+		return nil, core.NewAuthError(s.Name(), core.ReasonUpstreamError, fmt.Errorf("userinfo returned status %d", resp.StatusCode))
+	}
 	var info map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		return nil, core.ErrUnauthorized
+		return nil, core.NewAuthError(s.Name(), core.ReasonUpstreamError, err)
 	}
-
 	// Extract core.User
 	user, err := s.config.ExtractUser(ctx, info)
 	if err != nil {
-		return nil, core.ErrUnauthorized
+		return nil, core.NewAuthError(s.Name(), core.ReasonInvalidCredentials, err)
 	}
-	return user, nil
+	return &core.AuthResult{User: user, Strategy: s.Name(), Methods: []string{"oauth2"}, ExpiresAt: tok.Expiry}, nil
 }
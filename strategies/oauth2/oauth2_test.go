@@ -2,6 +2,7 @@ package oauth2_test
 
 import (
 	"context"
+	"errors"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -80,7 +81,13 @@ func TestOAuth2Strategy_Authenticate_NoCode(t *testing.T) {
 	strat := authoauth.New(cfg)
 	req := httptest.NewRequest("GET", "/", nil)
 	_, err := strat.Authenticate(context.Background(), req)
-	if err != core.ErrUnauthorized {
+	if !errors.Is(err, core.ErrUnauthorized) {
 		t.Errorf("expected ErrUnauthorized, got %v", err)
 	}
 }
+
+func TestOAuth2Strategy_Setup(t *testing.T) {
+	if err := authoauth.New(authoauth.Config{}).Setup(); !errors.Is(err, core.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
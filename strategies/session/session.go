@@ -2,7 +2,10 @@ package session
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"go-ez-auth/core"
 
@@ -11,12 +14,21 @@ import (
 
 // Config holds settings for the Session strategy.
 type Config struct {
-	Store       sessions.Store // Gorilla sessions store
-	SessionName string         // name of the session (cookie)
-	Key         string         // key in session.Values for user ID
-	UserStore   core.UserStore // backend to lookup users
+	Store       sessions.Store    // Gorilla sessions store
+	SessionName string            // name of the session (cookie)
+	Key         string            // key in session.Values for user ID
+	UserStore   core.UserStore    // backend to lookup users
+	Events      core.EventEmitter // optional; receives login, logout and impersonation events
+
+	// CanImpersonate authorizes an actor to act as target, typically by checking the actor's roles
+	// with an authz.Authorizer. It is checked when impersonation starts and on every impersonated
+	// request. Impersonation is disabled if it is nil.
+	CanImpersonate func(ctx context.Context, actor, target core.User) (bool, error)
 }
 
+// ErrNotImpersonating is returned by StopImpersonation when the session is not impersonating.
+var ErrNotImpersonating = errors.New("session: not impersonating")
+
 // Strategy implements core.Strategy for session-based auth.
 type Strategy struct {
 	config Config
@@ -32,37 +44,105 @@ func (s *Strategy) Name() string {
 	return "session"
 }
 
-// Setup configures cookie options for secure sessions if using CookieStore.
+// Setup validates the configuration and applies secure cookie options if using CookieStore.
 func (s *Strategy) Setup() error {
-	// Apply secure defaults to CookieStore
+	switch {
+	case s.config.Store == nil:
+		return fmt.Errorf("session: Store is required: %w", core.ErrInvalidConfig)
+	case s.config.SessionName == "":
+		return fmt.Errorf("session: SessionName is required: %w", core.ErrInvalidConfig)
+	case s.config.Key == "":
+		return fmt.Errorf("session: Key is required: %w", core.ErrInvalidConfig)
+	case s.config.UserStore == nil:
+		return fmt.Errorf("session: UserStore is required: %w", core.ErrInvalidConfig)
+	}
+	// Apply secure defaults to CookieStore, keeping its other options such as MaxAge and Domain
 	if cs, ok := s.config.Store.(*sessions.CookieStore); ok {
-		cs.Options = &sessions.Options{
-			Path:     "/",
-			HttpOnly: true,
-			Secure:   true,
-			SameSite: http.SameSiteLaxMode,
+		if cs.Options == nil {
+			cs.Options = &sessions.Options{}
 		}
+		cs.Options.Path = "/"
+		cs.Options.HttpOnly = true
+		cs.Options.Secure = true
+		cs.Options.SameSite = http.SameSiteLaxMode
 	}
 	return nil
 }
 
 // Authenticate retrieves the session, extracts user ID, and looks up the user.
 func (s *Strategy) Authenticate(ctx context.Context, r *http.Request) (core.User, error) {
+	res, err := s.AuthenticateResult(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return res.User, nil
+}
+
+// AuthenticateResult looks up the session user and reports when they logged in.
+func (s *Strategy) AuthenticateResult(ctx context.Context, r *http.Request) (*core.AuthResult, error) {
 	sess, err := s.config.Store.Get(r, s.config.SessionName)
 	if err != nil {
-		return nil, core.ErrUnauthorized
+		return nil, core.NewAuthError(s.Name(), core.ReasonInvalidCredentials, err)
 	}
 
 	raw, ok := sess.Values[s.config.Key].(string)
 	if !ok || raw == "" {
-		return nil, core.ErrUnauthorized
+		return nil, core.NoCredentialsError(s.Name())
 	}
 
 	user, err := s.config.UserStore.FindUserByID(ctx, raw)
 	if err != nil {
-		return nil, core.ErrUnauthorized
+		return nil, core.NewAuthError(s.Name(), core.StoreReason(err), err)
+	}
+	res := &core.AuthResult{User: user, Strategy: s.Name(), Methods: []string{"session"}}
+	if unix, ok := sess.Values[s.authTimeKey()].(int64); ok {
+		res.AuthTime = time.Unix(unix, 0)
 	}
-	return user, nil
+	if actorID, ok := sess.Values[s.actorKey()].(string); ok {
+		actor, err := s.config.UserStore.FindUserByID(ctx, actorID)
+		if err != nil {
+			return nil, s.impersonationError(core.StoreReason(err), err, raw, actorID)
+		}
+		// Re-check so that revoking the actor's rights ends impersonations in progress.
+		if err := s.authorizeImpersonation(ctx, actor, user); err != nil {
+			return nil, s.impersonationError(core.ReasonInvalidCredentials, err, raw, actorID)
+		}
+		res.Actor = actor
+	}
+	return res, nil
+}
+
+// impersonationError reports a rejected request in which actorID impersonates userID.
+func (s *Strategy) impersonationError(reason core.Reason, err error, userID, actorID string) *core.AuthError {
+	ae := core.NewAuthError(s.Name(), reason, err)
+	ae.UserID, ae.ActorID = userID, actorID
+	return ae
+}
+
+// authTimeKey is the session.Values key recording when the user logged in.
+func (s *Strategy) authTimeKey() string {
+	return s.config.Key + "_auth_time"
+}
+
+// actorKey is the session.Values key holding the real user's ID while impersonating.
+func (s *Strategy) actorKey() string {
+	return s.config.Key + "_actor"
+}
+
+// authorizeImpersonation returns an error wrapping core.ErrForbidden unless CanImpersonate allows
+// actor to act as target.
+func (s *Strategy) authorizeImpersonation(ctx context.Context, actor, target core.User) error {
+	if s.config.CanImpersonate == nil {
+		return fmt.Errorf("session: impersonation is disabled: %w", core.ErrForbidden)
+	}
+	ok, err := s.config.CanImpersonate(ctx, actor, target)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("session: %q may not impersonate %q: %w", actor.GetID(), target.GetID(), core.ErrForbidden)
+	}
+	return nil
 }
 
 // Login saves the user's ID in a new session and protects against fixation by issuing a fresh cookie.
@@ -71,8 +151,99 @@ func (s *Strategy) Login(w http.ResponseWriter, r *http.Request, user core.User)
 	if err != nil {
 		return core.ErrUnauthorized
 	}
-	// Set user ID in session
+	// Set user ID and login time in session, ending any impersonation from an earlier login
 	sess.Values[s.config.Key] = user.GetID()
+	sess.Values[s.authTimeKey()] = time.Now().Unix()
+	delete(sess.Values, s.actorKey())
 	// Save writes a new cookie with updated data
-	return sess.Save(r, w)
+	if err := sess.Save(r, w); err != nil {
+		return err
+	}
+	s.emit(r, core.EventLoginSuccess, user.GetID(), "")
+	return nil
+}
+
+// StartImpersonation lets the user logged in to the session act as the user with targetID. Requests
+// then authenticate as the target, with the real user in core.AuthResult.Actor. It fails with an error
+// wrapping core.ErrForbidden if CanImpersonate refuses or the session is already impersonating.
+func (s *Strategy) StartImpersonation(w http.ResponseWriter, r *http.Request, targetID string) error {
+	ctx := r.Context()
+	res, err := s.AuthenticateResult(ctx, r)
+	if err != nil {
+		return err
+	}
+	if res.Actor != nil {
+		return fmt.Errorf("session: already impersonating %q: %w", res.User.GetID(), core.ErrForbidden)
+	}
+	target, err := s.config.UserStore.FindUserByID(ctx, targetID)
+	if err != nil {
+		return err
+	}
+	if err := s.authorizeImpersonation(ctx, res.User, target); err != nil {
+		return err
+	}
+	sess, err := s.config.Store.Get(r, s.config.SessionName)
+	if err != nil {
+		return core.ErrUnauthorized
+	}
+	sess.Values[s.config.Key] = target.GetID()
+	sess.Values[s.actorKey()] = res.User.GetID()
+	if err := sess.Save(r, w); err != nil {
+		return err
+	}
+	s.emit(r, core.EventImpersonationStart, target.GetID(), res.User.GetID())
+	return nil
+}
+
+// StopImpersonation returns the session to the real user. It returns ErrNotImpersonating if the
+// session is not impersonating.
+func (s *Strategy) StopImpersonation(w http.ResponseWriter, r *http.Request) error {
+	sess, err := s.config.Store.Get(r, s.config.SessionName)
+	if err != nil {
+		return core.ErrUnauthorized
+	}
+	actorID, ok := sess.Values[s.actorKey()].(string)
+	if !ok {
+		return ErrNotImpersonating
+	}
+	targetID, _ := sess.Values[s.config.Key].(string)
+	sess.Values[s.config.Key] = actorID
+	delete(sess.Values, s.actorKey())
+	if err := sess.Save(r, w); err != nil {
+		return err
+	}
+	s.emit(r, core.EventImpersonationEnd, targetID, actorID)
+	return nil
+}
+
+// Logout clears the user from the session and expires the session cookie.
+func (s *Strategy) Logout(w http.ResponseWriter, r *http.Request) error {
+	sess, err := s.config.Store.Get(r, s.config.SessionName)
+	if err != nil {
+		return core.ErrUnauthorized
+	}
+	userID, _ := sess.Values[s.config.Key].(string)
+	actorID, _ := sess.Values[s.actorKey()].(string)
+	delete(sess.Values, s.config.Key)
+	delete(sess.Values, s.authTimeKey())
+	delete(sess.Values, s.actorKey())
+	if sess.Options == nil {
+		sess.Options = &sessions.Options{}
+	}
+	sess.Options.MaxAge = -1
+	if err := sess.Save(r, w); err != nil {
+		return err
+	}
+	s.emit(r, core.EventLogout, userID, actorID)
+	return nil
+}
+
+// emit publishes an event to Config.Events, if set. actorID is empty unless impersonating.
+func (s *Strategy) emit(r *http.Request, t core.EventType, userID, actorID string) {
+	if s.config.Events == nil {
+		return
+	}
+	e := core.NewEvent(t, r)
+	e.Strategy, e.UserID, e.ActorID = s.Name(), userID, actorID
+	s.config.Events.Emit(r.Context(), e)
 }
@@ -2,8 +2,11 @@ package session_test
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"go-ez-auth/core"
 	"go-ez-auth/stores"
@@ -22,7 +25,7 @@ func TestAuthenticate_NoSession(t *testing.T) {
 	s := session.New(session.Config{Store: store, SessionName: "sess", Key: "user_id", UserStore: stores.NewInMemoryUserStore()})
 	req := httptest.NewRequest("GET", "/", nil)
 	_, err := s.Authenticate(context.Background(), req)
-	if err != core.ErrUnauthorized {
+	if !errors.Is(err, core.ErrUnauthorized) {
 		t.Errorf("expected ErrUnauthorized, got %v", err)
 	}
 }
@@ -43,7 +46,7 @@ func TestAuthenticate_InvalidUserID(t *testing.T) {
 		req2.AddCookie(c)
 	}
 	_, err := s.Authenticate(context.Background(), req2)
-	if err != core.ErrUnauthorized {
+	if !errors.Is(err, core.ErrUnauthorized) {
 		t.Errorf("expected ErrUnauthorized for unknown user, got %v", err)
 	}
 }
@@ -98,3 +101,204 @@ func TestLogin_ThenAuthenticate(t *testing.T) {
 		t.Errorf("expected user u1, got %s", user.GetID())
 	}
 }
+
+func TestSetup_Validation(t *testing.T) {
+	store := sessions.NewCookieStore([]byte("secret"))
+	if err := session.New(session.Config{Store: store, SessionName: "sess", Key: "user_id"}).Setup(); !errors.Is(err, core.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig without UserStore, got %v", err)
+	}
+	store.Options.MaxAge, store.Options.Domain = 3600, "example.com"
+	s := session.New(session.Config{Store: store, SessionName: "sess", Key: "user_id", UserStore: stores.NewInMemoryUserStore()})
+	if err := core.NewAuthenticator().Register(s); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if !store.Options.HttpOnly || !store.Options.Secure {
+		t.Errorf("expected secure cookie defaults, got %+v", store.Options)
+	}
+	if store.Options.MaxAge != 3600 || store.Options.Domain != "example.com" {
+		t.Errorf("expected MaxAge and Domain to be kept, got %+v", store.Options)
+	}
+}
+
+func TestLoginLogout_Events(t *testing.T) {
+	store := sessions.NewCookieStore([]byte("secret"))
+	dummy := dummyUser{"u1"}
+	events := core.NewAuthenticator()
+	var got []core.EventType
+	events.Subscribe(func(ctx context.Context, e core.Event) { got = append(got, e.Type) })
+	s := session.New(session.Config{Store: store, SessionName: "sess", Key: "user_id", UserStore: stores.NewInMemoryUserStore(dummy), Events: events})
+
+	w := httptest.NewRecorder()
+	if err := s.Login(w, httptest.NewRequest("GET", "/", nil), dummy); err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	w2 := httptest.NewRecorder()
+	if err := s.Logout(w2, req); err != nil {
+		t.Fatalf("Logout error: %v", err)
+	}
+	if len(got) != 2 || got[0] != core.EventLoginSuccess || got[1] != core.EventLogout {
+		t.Errorf("expected login and logout events, got %v", got)
+	}
+
+	// The expired cookie no longer authenticates.
+	req3 := httptest.NewRequest("GET", "/", nil)
+	for _, c := range w2.Result().Cookies() {
+		if c.MaxAge >= 0 {
+			req3.AddCookie(c)
+		}
+	}
+	if _, err := s.Authenticate(context.Background(), req3); !errors.Is(err, core.ErrNoCredentials) {
+		t.Errorf("expected no credentials after logout, got %v", err)
+	}
+}
+
+func TestAuthenticateResult_AuthTime(t *testing.T) {
+	store := sessions.NewCookieStore([]byte("secret"))
+	dummy := dummyUser{"u1"}
+	s := session.New(session.Config{Store: store, SessionName: "sess", Key: "user_id", UserStore: stores.NewInMemoryUserStore(dummy)})
+
+	before := time.Now().Truncate(time.Second)
+	w := httptest.NewRecorder()
+	if err := s.Login(w, httptest.NewRequest("GET", "/", nil), dummy); err != nil {
+		t.Fatalf("Login error: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	res, err := s.AuthenticateResult(context.Background(), req)
+	if err != nil {
+		t.Fatalf("AuthenticateResult: %v", err)
+	}
+	if res.Strategy != "session" || res.AuthTime.Before(before) {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+// withCookies returns a new request carrying the cookies set on w.
+func withCookies(w *httptest.ResponseRecorder) *http.Request {
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	return req
+}
+
+func TestImpersonation(t *testing.T) {
+	store := sessions.NewCookieStore([]byte("secret"))
+	admin, customer := dummyUser{"admin"}, dummyUser{"customer"}
+	events := core.NewAuthenticator()
+	var got []core.Event
+	events.Subscribe(func(ctx context.Context, e core.Event) { got = append(got, e) })
+	allowed := true
+	s := session.New(session.Config{
+		Store: store, SessionName: "sess", Key: "user_id",
+		UserStore: stores.NewInMemoryUserStore(admin, customer),
+		Events:    events,
+		CanImpersonate: func(ctx context.Context, actor, target core.User) (bool, error) {
+			return allowed && actor.GetID() == "admin", nil
+		},
+	})
+
+	w := httptest.NewRecorder()
+	s.Login(w, httptest.NewRequest("GET", "/", nil), admin)
+	w2 := httptest.NewRecorder()
+	if err := s.StartImpersonation(w2, withCookies(w), "customer"); err != nil {
+		t.Fatalf("StartImpersonation: %v", err)
+	}
+	res, err := s.AuthenticateResult(context.Background(), withCookies(w2))
+	if err != nil || res.User.GetID() != "customer" || res.Actor == nil || res.Actor.GetID() != "admin" {
+		t.Fatalf("expected customer impersonated by admin, got %+v %v", res, err)
+	}
+	if last := got[len(got)-1]; last.Type != core.EventImpersonationStart || last.UserID != "customer" || last.ActorID != "admin" {
+		t.Errorf("unexpected start event %+v", last)
+	}
+
+	// Nested impersonation is refused.
+	if err := s.StartImpersonation(httptest.NewRecorder(), withCookies(w2), "admin"); !errors.Is(err, core.ErrForbidden) {
+		t.Errorf("expected ErrForbidden for nested impersonation, got %v", err)
+	}
+
+	// Revoking the right ends the impersonation.
+	allowed = false
+	if _, err := s.Authenticate(context.Background(), withCookies(w2)); !errors.Is(err, core.ErrForbidden) {
+		t.Errorf("expected impersonation to be rejected once revoked, got %v", err)
+	}
+	allowed = true
+
+	w3 := httptest.NewRecorder()
+	if err := s.StopImpersonation(w3, withCookies(w2)); err != nil {
+		t.Fatalf("StopImpersonation: %v", err)
+	}
+	res, err = s.AuthenticateResult(context.Background(), withCookies(w3))
+	if err != nil || res.User.GetID() != "admin" || res.Actor != nil {
+		t.Errorf("expected admin after stopping, got %+v %v", res, err)
+	}
+	if err := s.StopImpersonation(httptest.NewRecorder(), withCookies(w3)); !errors.Is(err, session.ErrNotImpersonating) {
+		t.Errorf("expected ErrNotImpersonating, got %v", err)
+	}
+}
+
+func TestImpersonation_Forbidden(t *testing.T) {
+	store := sessions.NewCookieStore([]byte("secret"))
+	user, other := dummyUser{"u1"}, dummyUser{"u2"}
+	s := session.New(session.Config{Store: store, SessionName: "sess", Key: "user_id", UserStore: stores.NewInMemoryUserStore(user, other)})
+
+	w := httptest.NewRecorder()
+	s.Login(w, httptest.NewRequest("GET", "/", nil), user)
+	if err := s.StartImpersonation(httptest.NewRecorder(), withCookies(w), "u2"); !errors.Is(err, core.ErrForbidden) {
+		t.Errorf("expected ErrForbidden without CanImpersonate, got %v", err)
+	}
+}
+
+func TestImpersonation_LoginEnds(t *testing.T) {
+	store := sessions.NewCookieStore([]byte("secret"))
+	admin, customer, other := dummyUser{"admin"}, dummyUser{"customer"}, dummyUser{"other"}
+	s := session.New(session.Config{
+		Store: store, SessionName: "sess", Key: "user_id",
+		UserStore: stores.NewInMemoryUserStore(admin, customer, other),
+		CanImpersonate: func(ctx context.Context, actor, target core.User) (bool, error) {
+			return actor.GetID() == "admin", nil
+		},
+	})
+
+	w := httptest.NewRecorder()
+	s.Login(w, httptest.NewRequest("GET", "/", nil), admin)
+	w2 := httptest.NewRecorder()
+	if err := s.StartImpersonation(w2, withCookies(w), "customer"); err != nil {
+		t.Fatalf("StartImpersonation: %v", err)
+	}
+	w3 := httptest.NewRecorder()
+	if err := s.Login(w3, withCookies(w2), other); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	res, err := s.AuthenticateResult(context.Background(), withCookies(w3))
+	if err != nil || res.User.GetID() != "other" || res.Actor != nil {
+		t.Errorf("expected plain login as other, got %+v %v", res, err)
+	}
+}
+
+// optionlessStore hands out sessions without Options, as some third-party stores do.
+type optionlessStore struct{ sessions.Store }
+
+func (o optionlessStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	sess := sessions.NewSession(o, name)
+	sess.Values["user_id"] = "u1"
+	sess.Options = nil
+	return sess, nil
+}
+
+func (optionlessStore) Save(r *http.Request, w http.ResponseWriter, s *sessions.Session) error {
+	return nil
+}
+
+func TestLogout_NilOptions(t *testing.T) {
+	s := session.New(session.Config{Store: optionlessStore{}, SessionName: "sess", Key: "user_id", UserStore: stores.NewInMemoryUserStore(dummyUser{"u1"})})
+	if err := s.Logout(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil)); err != nil {
+		t.Errorf("Logout: %v", err)
+	}
+}